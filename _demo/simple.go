@@ -1,15 +1,13 @@
 // This is a simple demo of the project thus far.
-// In this demo, we initiate a termbox session,
-// and launch a console that takes up the initial size
-// of the terminal.
+// In this demo, we launch two consoles that split
+// the terminal between them.
 package main
 
 
 import (
+	"fmt"
 	"os"
 
-	tb "github.com/nsf/termbox-go"
-
 	"github.com/mcprice30/ugcli"
 	"github.com/mcprice30/ugcli/console"
 )
@@ -17,33 +15,34 @@ import (
 // main is the entry point for the application.
 func main() {
 
-	// Attempt to initialize the termbox.
-	// TODO: contain inside ugcli run method.
-	if err := tb.Init(); err != nil {
-		os.Exit(1)
-	}
-
-	// Clear the termbox.
-	if err := tb.Clear(tb.ColorDefault, tb.ColorDefault); err != nil {
-		panic(err)
-	}
-
-	// Create a new console taking the size of the terminal.
-	w, h := tb.Size()
-	con := console.NewConsole(0, 0, w, h)
+	// Create two consoles. Their size doesn't matter, since they will be placed
+	// by the application's layout.
+	left := console.NewConsole(0, 0, 0, 0)
+	right := console.NewConsole(0, 0, 0, 0)
 
 	// prefix tree completer.
 	completer := console.NewListCompleter([]string{"a", "ab", "abc", "bad",
 		"carrot", "jane", "jack"})
-	con.SetCompleter(completer)
+	left.SetCompleter(completer)
+	right.SetCompleter(completer)
 
-	// Initialize ugcli application and add console.
+	// Initialize ugcli application and add the consoles. Focus can be moved
+	// between them with Ctrl-O.
 	cli := ugcli.NewCli()
-	cli.AddComponent(con)
-
-	// Launch the application.
-	cli.Run()
-
-	// Close the termbox session when done.
-	tb.Close()
+	leftHandle := cli.AddComponent(left)
+	rightHandle := cli.AddComponent(right)
+
+	// Place the consoles side by side, each with a border, splitting the width
+	// of the terminal evenly between them.
+	cli.SetLayout(ugcli.HSplit(
+		ugcli.Pane(leftHandle).Border("left"),
+		ugcli.Pane(rightHandle).Border("right"),
+	))
+
+	// Launch the application. This takes care of setting up and restoring the
+	// terminal.
+	if err := cli.Run(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
 }
@@ -0,0 +1,356 @@
+package console
+
+// command_completer.go contains a completer built from a tree of commands,
+// each of which may have subcommands, flags and positional arguments.
+
+import (
+	"context"
+	"strings"
+)
+
+// ValueType describes what kind of value a flag takes.
+type ValueType int
+
+const (
+	// NoValue flags are switches, which take no value.
+	NoValue ValueType = iota
+
+	// StringValue flags take any text as their value.
+	StringValue
+
+	// IntValue flags take an integer as their value.
+	IntValue
+
+	// EnumValue flags take one of the flag's Values as their value.
+	EnumValue
+)
+
+// Command describes a command, or a subcommand of another command, for
+// NewCommandCompleter.
+type Command struct {
+
+	// Name is what is typed to run the command.
+	Name string
+
+	// Description, if set, is shown alongside the command when it is
+	// suggested.
+	Description string
+
+	// Subcommands holds the commands which may be typed as the command's
+	// first argument.
+	Subcommands []*Command
+
+	// Flags holds the flags the command accepts. Flags of a command are also
+	// accepted by all of its subcommands.
+	Flags []*Flag
+
+	// Args holds completers for the command's positional arguments, in order.
+	Args []Completer
+
+	// MoreArgs, if set, completes any positional arguments past the end of
+	// Args, for commands taking any number of them.
+	MoreArgs Completer
+}
+
+// Flag describes a flag accepted by a Command.
+type Flag struct {
+
+	// Name is the name of the flag, which is typed after "--".
+	Name string
+
+	// Description, if set, is shown alongside the flag when it is suggested.
+	Description string
+
+	// Type indicates what kind of value the flag takes, if any. Values may be
+	// given as the next argument, or after "=", as in "--name=value".
+	Type ValueType
+
+	// Values holds the values allowed for an EnumValue flag.
+	Values []string
+
+	// Complete, if set, completes the flag's value, in place of Values.
+	Complete Completer
+}
+
+// commandCompleter is a builtin implementation of the completer interface,
+// which completes commands according to a tree of commands.
+type commandCompleter struct {
+
+	// root is a command whose subcommands are the top level commands.
+	root *Command
+
+	// names holds a prefix tree of the names of each command's subcommands.
+	names map[*Command]*listCompleter
+
+	// flags holds a prefix tree of the flags accepted by each command,
+	// including those of its ancestors, with their dashes.
+	flags map[*Command]*listCompleter
+
+	// accepted holds the flags accepted by each command, including those of
+	// its ancestors, by name.
+	accepted map[*Command]map[string]*Flag
+
+	// values holds a prefix tree of the allowed values of each EnumValue
+	// flag.
+	values map[*Flag]*listCompleter
+
+	// background indicates whether any argument or flag value is completed by
+	// a ContextCompleter.
+	background bool
+}
+
+// contextCommandCompleter is a commandCompleter for a tree of commands with
+// an argument or flag value completed by a ContextCompleter. It is itself a
+// ContextCompleter, so that consoles run it in the background.
+type contextCommandCompleter struct {
+	*commandCompleter
+}
+
+// SuggestContext implements the ContextCompleter interface.
+func (cc contextCommandCompleter) SuggestContext(ctx context.Context, req CompletionRequest) (string, []Suggestion, error) {
+	return cc.suggest(ctx, req)
+}
+
+// NewCommandCompleter produces a completer for the given top level commands.
+// The first argument of a line is completed from the names of the commands,
+// and later arguments from the subcommands, flags and positional arguments of
+// whichever command they follow. For example, given a deploy command whose
+// first argument is an environment and which accepts a --region flag,
+// "deploy <TAB>" lists environments, while "deploy prod --re<TAB>" completes
+// to "deploy prod --region".
+//
+// If any argument or flag value is completed by a ContextCompleter, the
+// completer returned is also a ContextCompleter, so that it is run in the
+// background, as that completer would be.
+func NewCommandCompleter(commands ...*Command) Completer {
+	cc := &commandCompleter{
+		root:     &Command{Subcommands: commands},
+		names:    map[*Command]*listCompleter{},
+		flags:    map[*Command]*listCompleter{},
+		accepted: map[*Command]map[string]*Flag{},
+		values:   map[*Flag]*listCompleter{},
+	}
+	cc.add(cc.root, map[string]*Flag{})
+	if cc.background {
+		return contextCommandCompleter{cc}
+	}
+	return cc
+}
+
+// add builds the prefix trees for a command and its subcommands, given the
+// flags accepted by its ancestors.
+func (cc *commandCompleter) add(cmd *Command, inherited map[string]*Flag) {
+	accepted := map[string]*Flag{}
+	for name, flag := range inherited {
+		accepted[name] = flag
+	}
+	for _, flag := range cmd.Flags {
+		accepted[flag.Name] = flag
+		if flag.Type == EnumValue {
+			cc.values[flag] = newListCompleter(flag.Values)
+		}
+		cc.checkBackground(flag.Complete)
+	}
+	for _, arg := range cmd.Args {
+		cc.checkBackground(arg)
+	}
+	cc.checkBackground(cmd.MoreArgs)
+	flagNames := []string{}
+	for name := range accepted {
+		flagNames = append(flagNames, "--"+name)
+	}
+	cc.accepted[cmd] = accepted
+	cc.flags[cmd] = newListCompleter(flagNames)
+
+	names := []string{}
+	for _, sub := range cmd.Subcommands {
+		names = append(names, sub.Name)
+		cc.add(sub, accepted)
+	}
+	cc.names[cmd] = newListCompleter(names)
+}
+
+// checkBackground notes whether a completer of an argument or flag value runs
+// in the background.
+func (cc *commandCompleter) checkBackground(comp Completer) {
+	if _, ok := comp.(ContextCompleter); ok {
+		cc.background = true
+	}
+}
+
+// Complete implements the Completer interface, treating the whole line as
+// arguments to be completed.
+func (cc *commandCompleter) Complete(input string) (string, []string) {
+	return completeLastArg(cc, input)
+}
+
+// CompleteArg implements the ArgCompleter interface.
+func (cc *commandCompleter) CompleteArg(req CompletionRequest) (string, []string) {
+	prefix, suggestions := cc.Suggest(req)
+	return prefix, suggestionTexts(suggestions)
+}
+
+// Suggest implements the SuggestionCompleter interface, describing each
+// subcommand and flag suggested with its Description. Any ContextCompleter
+// completing an argument is waited for.
+func (cc *commandCompleter) Suggest(req CompletionRequest) (string, []Suggestion) {
+	prefix, suggestions, err := cc.suggest(context.Background(), req)
+	if err != nil {
+		return req.Word, []Suggestion{}
+	}
+	return prefix, suggestions
+}
+
+// suggest suggests completions for the argument described by req, passing
+// ctx on to any ContextCompleter completing it.
+func (cc *commandCompleter) suggest(ctx context.Context, req CompletionRequest) (string, []Suggestion, error) {
+
+	// Follow the arguments before the one being completed down the tree,
+	// keeping track of how many positional arguments the command has been
+	// given, and whether the last argument was a flag waiting for its value.
+	cmd := cc.root
+	positional := 0
+	var pending *Flag
+	for _, arg := range req.Args {
+		if pending != nil {
+			pending = nil
+		} else if strings.HasPrefix(arg, "-") && len(arg) > 1 {
+			name := strings.TrimLeft(arg, "-")
+			if flag := cc.accepted[cmd][name]; flag != nil && flag.Type != NoValue {
+				pending = flag
+			}
+		} else if sub := cc.subcommand(cmd, arg); sub != nil && positional == 0 {
+			cmd = sub
+		} else {
+			positional++
+		}
+	}
+
+	word := req.Word
+	if pending != nil {
+		return cc.suggestValue(ctx, pending, req)
+	} else if strings.HasPrefix(word, "-") {
+		if eq := strings.Index(word, "="); eq >= 0 {
+			flag := cc.accepted[cmd][strings.TrimLeft(word[:eq], "-")]
+			if flag == nil {
+				return word, []Suggestion{}, nil
+			}
+			req.Word = word[eq+1:]
+			prefix, suggestions, err := cc.suggestValue(ctx, flag, req)
+			return word[:eq+1] + prefix, suggestions, err
+		}
+		prefix, options := cc.flags[cmd].Complete(word)
+		suggestions := sortedSuggestions(options)
+		for i := range suggestions {
+			flag := cc.accepted[cmd][strings.TrimLeft(suggestions[i].Text, "-")]
+			suggestions[i].Description = flag.Description
+		}
+		return prefix, suggestions, nil
+	}
+
+	prefix, suggestions := word, []Suggestion{}
+	if positional == 0 {
+		var options []string
+		prefix, options = cc.names[cmd].Complete(word)
+		suggestions = sortedSuggestions(options)
+		for i := range suggestions {
+			sub := cc.subcommand(cmd, suggestions[i].Text)
+			suggestions[i].Description = sub.Description
+		}
+	}
+	argComp := cmd.MoreArgs
+	if positional < len(cmd.Args) {
+		argComp = cmd.Args[positional]
+	}
+	if argComp == nil {
+		return prefix, suggestions, nil
+	} else if len(suggestions) == 0 {
+		return suggestWith(ctx, argComp, req)
+	}
+	_, more, err := suggestWith(ctx, argComp, req)
+	suggestions = append(suggestions, more...)
+	return commonPrefix(word, suggestionTexts(suggestions)), suggestions, err
+}
+
+// subcommand returns the subcommand of cmd with the given name, or nil if
+// there isn't one.
+func (cc *commandCompleter) subcommand(cmd *Command, name string) *Command {
+	for _, sub := range cmd.Subcommands {
+		if sub.Name == name {
+			return sub
+		}
+	}
+	return nil
+}
+
+// suggestValue suggests values for a flag.
+func (cc *commandCompleter) suggestValue(ctx context.Context, flag *Flag, req CompletionRequest) (string, []Suggestion, error) {
+	if flag.Complete != nil {
+		return suggestWith(ctx, flag.Complete, req)
+	} else if values, ok := cc.values[flag]; ok {
+		prefix, options := values.Complete(req.Word)
+		return prefix, sortedSuggestions(options), nil
+	}
+	return req.Word, []Suggestion{}, nil
+}
+
+// suggestWith asks a completer for suggestions for the argument described by
+// req, whatever kind of completer it is, passing ctx on to a ContextCompleter.
+// Only a ContextCompleter can fail.
+func suggestWith(ctx context.Context, comp Completer, req CompletionRequest) (string, []Suggestion, error) {
+	switch comp := comp.(type) {
+	case ContextCompleter:
+		return comp.SuggestContext(ctx, req)
+	case SuggestionCompleter:
+		prefix, suggestions := comp.Suggest(req)
+		return prefix, suggestions, nil
+	case ArgCompleter:
+		prefix, options := comp.CompleteArg(req)
+		return prefix, sortedSuggestions(options), nil
+	}
+	prefix, options := comp.Complete(req.Word)
+	return prefix, sortedSuggestions(options), nil
+}
+
+// completeLastArg implements Completer.Complete for an ArgCompleter, by
+// completing the last argument of the line, or a new argument if the line
+// ends in whitespace.
+func completeLastArg(ac ArgCompleter, input string) (string, []string) {
+	tokens := Tokenize(input)
+	req := CompletionRequest{Line: input, Cursor: len(input), Args: []string{}}
+	start := len(input)
+	var quote byte
+	if n := len(tokens); n > 0 && tokens[n-1].End == len(input) {
+		req.Word, quote = tokens[n-1].Text, tokens[n-1].Quote
+		start = tokens[n-1].Start
+		tokens = tokens[:n-1]
+	}
+	for _, token := range tokens {
+		req.Args = append(req.Args, token.Text)
+	}
+
+	prefix, options := ac.CompleteArg(req)
+	return input[:start] + quoteWord(prefix, quote), options
+}
+
+// commonPrefix returns the longest prefix shared by every option, or word if
+// there are no options. The prefix is made up of whole characters, so it never
+// ends partway through a character that the options continue differently.
+func commonPrefix(word string, options []string) string {
+	if len(options) == 0 {
+		return word
+	}
+	prefix := options[0]
+	for _, option := range options[1:] {
+		i := 0
+		for i < len(prefix) && i < len(option) {
+			next := nextBoundary(prefix, i)
+			if nextBoundary(option, i) != next || prefix[i:next] != option[i:next] {
+				break
+			}
+			i = next
+		}
+		prefix = prefix[:i]
+	}
+	return prefix
+}
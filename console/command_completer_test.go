@@ -0,0 +1,77 @@
+package console
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// newDeployCompleter returns a command completer for a small deployment tool.
+func newDeployCompleter() Completer {
+	return NewCommandCompleter(
+		&Command{
+			Name:  "deploy",
+			Flags: []*Flag{{Name: "region", Type: EnumValue, Values: []string{"us-east", "us-west", "eu"}}},
+			Args:  []Completer{NewListCompleter([]string{"prod", "staging"})},
+		},
+		&Command{
+			Name:  "db",
+			Flags: []*Flag{{Name: "verbose"}, {Name: "timeout", Type: IntValue}},
+			Subcommands: []*Command{
+				{Name: "migrate"},
+				{Name: "dump", Flags: []*Flag{{Name: "format", Type: EnumValue, Values: []string{"sql", "csv"}}}},
+			},
+		},
+	)
+}
+
+func TestCommandCompleter(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		options []string
+	}{
+		{"", "d", []string{"db", "deploy"}},
+		{"de", "deploy", []string{"deploy"}},
+		{"deploy ", "deploy ", []string{"prod", "staging"}},
+		{"deploy st", "deploy staging", []string{"staging"}},
+		{"deploy prod --re", "deploy prod --region", []string{"--region"}},
+		{"deploy prod --region us", "deploy prod --region us-", []string{"us-east", "us-west"}},
+		{"deploy prod --region=e", "deploy prod --region=eu", []string{"eu"}},
+		{"db ", "db ", []string{"dump", "migrate"}},
+		{"db dump --", "db dump --", []string{"--format", "--timeout", "--verbose"}},
+		{"db --verbose m", "db --verbose migrate", []string{"migrate"}},
+		{"db --timeout m", "db --timeout m", []string{}},
+		{"db dump --format ", "db dump --format ", []string{"csv", "sql"}},
+		{"db migrate --format=", "db migrate --format=", []string{}},
+		{"nope ", "nope ", []string{}},
+	}
+	comp := newDeployCompleter()
+	for _, tt := range tests {
+		got, options := comp.Complete(tt.input)
+		sort.Strings(options)
+		if got != tt.want || !reflect.DeepEqual(options, tt.options) {
+			t.Errorf("Complete(%q) = %q, %q, want %q, %q", tt.input, got, options,
+				tt.want, tt.options)
+		}
+	}
+}
+
+func TestCommonPrefix(t *testing.T) {
+	tests := []struct {
+		word    string
+		options []string
+		want    string
+	}{
+		{"x", nil, "x"},
+		{"", []string{"status", "stash"}, "sta"},
+		{"", []string{"é", "ê"}, ""},
+		{"", []string{"café", "cafés"}, "café"},
+		{"", []string{"é", "è"}, ""},
+	}
+	for _, tt := range tests {
+		if got := commonPrefix(tt.word, tt.options); got != tt.want {
+			t.Errorf("commonPrefix(%q, %q) = %q, want %q", tt.word, tt.options, got, tt.want)
+		}
+	}
+}
@@ -1,5 +1,9 @@
 package console
 
+import (
+	"sort"
+)
+
 // Completer is an interface that can be implemented to allow users custom
 // tab-completion rules inside an ugCLI console.
 type Completer interface {
@@ -11,6 +15,89 @@ type Completer interface {
 	Complete(input string) (prefix string, recommendations []string)
 }
 
+// ArgCompleter is a Completer which completes a single argument of the
+// current line, rather than the whole line. Consoles split the line into
+// arguments with Tokenize, then complete the argument the cursor is in, or a
+// new one if the cursor isn't in any, replacing only that argument with the
+// result.
+type ArgCompleter interface {
+	Completer
+
+	// CompleteArg takes the argument being completed, along with the
+	// arguments before it, and returns a common prefix to expand the argument
+	// to, along with a list of potential recommendations for it. The prefix
+	// and recommendations are unquoted; the console quotes the prefix as
+	// needed when placing it into the line.
+	CompleteArg(req CompletionRequest) (prefix string, recommendations []string)
+}
+
+// CompletionRequest describes the argument that an ArgCompleter is asked to
+// complete.
+type CompletionRequest struct {
+
+	// Line is the whole current line, as typed.
+	Line string
+
+	// Cursor is the offset into Line, in bytes, of the cursor.
+	Cursor int
+
+	// Args holds the arguments before the one being completed, unquoted.
+	Args []string
+
+	// Word is the argument being completed, unquoted, up to the cursor.
+	Word string
+}
+
+// Suggestion is a single recommendation from a SuggestionCompleter, along with
+// details of how to show it.
+type Suggestion struct {
+
+	// Text is the recommendation itself.
+	Text string
+
+	// Matched holds the offsets into Text, in bytes, of the characters which
+	// matched what the user typed. They are highlighted when the suggestion is
+	// shown.
+	Matched []int
+
+	// Description, if set, is shown alongside the suggestion in the
+	// completion menu.
+	Description string
+}
+
+// SuggestionCompleter is an ArgCompleter which gives details of each of its
+// recommendations, such as which of their characters matched. Consoles prefer
+// Suggest to CompleteArg, when a completer implements it, and show the
+// suggestions in the order given, rather than alphabetically, so that they may
+// be ranked.
+type SuggestionCompleter interface {
+	ArgCompleter
+
+	// Suggest is as CompleteArg, returning suggestions rather than plain
+	// recommendations.
+	Suggest(req CompletionRequest) (prefix string, suggestions []Suggestion)
+}
+
+// sortedSuggestions returns suggestions for the given recommendations, in
+// alphabetical order.
+func sortedSuggestions(options []string) []Suggestion {
+	sort.Strings(options)
+	suggestions := make([]Suggestion, len(options))
+	for i, option := range options {
+		suggestions[i] = Suggestion{Text: option}
+	}
+	return suggestions
+}
+
+// suggestionTexts returns the text of each suggestion.
+func suggestionTexts(suggestions []Suggestion) []string {
+	texts := make([]string, len(suggestions))
+	for i, s := range suggestions {
+		texts[i] = s.Text
+	}
+	return texts
+}
+
 // listCompleter is a default, builtin implementation of the completer
 // interface. It uses a prefix tree (trie) and recommends all commands
 // stored within its list that share a prefix with the given input.
@@ -25,8 +112,13 @@ type listCompleter struct {
 
 // NewListCompleter will take a list of potential command words and produce
 // a completer that return all elements of the list that have the given
-// line as a prefix.
+// argument as a prefix.
 func NewListCompleter(list []string) Completer {
+	return newListCompleter(list)
+}
+
+// newListCompleter builds the list completer returned by NewListCompleter.
+func newListCompleter(list []string) *listCompleter {
 
 	// Initialize the head of the prefix tree.
 	head := &lcNode{
@@ -89,6 +181,12 @@ func (lc *listCompleter) Complete(input string) (string, []string) {
 	return lcNodeDFS(n, input, []string{})
 }
 
+// CompleteArg implements the ArgCompleter interface, completing the argument
+// under the cursor just as Complete would complete a whole line.
+func (lc *listCompleter) CompleteArg(req CompletionRequest) (string, []string) {
+	return lc.Complete(req.Word)
+}
+
 // lcNodeDFS is a utility function used to traverse the prefix tree.
 // Given a current node, the running string we are at, and all previously
 // added substrings, it will return all strings stored as children of the
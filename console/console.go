@@ -9,12 +9,29 @@
 // or a completer, which will implement tab completion within the console.
 package console
 
+import (
+	"sync"
+
+	"github.com/mcprice30/ugcli"
+)
+
 // defaultPrompt indicates the default prefix to be displayed before all
 // commands wihtin the console.
 const defaultPrompt = "> "
 
+// defaultContinuationPrompt indicates the default prefix to be displayed
+// before every line of a command after the first, for commands which span
+// several lines.
+const defaultContinuationPrompt = "... "
+
+// defaultScrollback indicates the default maximum number of lines of output
+// that the console remembers, both so that the user can scroll back through
+// them and so that they can be repainted after the console is resized.
+const defaultScrollback = 1000
+
 // bufferSize indicates the maximum number of previously executed commands
-// to buffer in terms of using the up/down arrows to view past commands.
+// that the default history remembers, in terms of using the up/down arrows to
+// view past commands.
 const bufferSize = 100
 
 // Console represents a console component of a command line application.
@@ -22,6 +39,14 @@ const bufferSize = 100
 // applications to deal with custom interations with the user.
 type Console struct {
 
+	// Guards the console's state against the goroutines which may write to it
+	// or read from it, while the console runs. See Writer and ReadLine. It is
+	// held with lock and released with unlock.
+	mu sync.Mutex
+
+	// Holds output printed while the console is held.
+	out outputQueue
+
 	// Which cell row of the terminal the console starts at.
 	top int
 
@@ -34,28 +59,76 @@ type Console struct {
 	// How many cell rows tall the console is.
 	height int
 
-	// The current cell column the cursor is located at.
-	// Note that this is indexed from 0 starting with the leftmost column of the
-	// terminal window, NOT the top row of the console.
-	cursorX int
+	// Whether the console extends to the right edge of the terminal, rather
+	// than having a fixed width.
+	fillWidth bool
 
-	// The current cell row the cursor is located at.
-	// Note that this is indexed from 0 starting with the top row of the terminal
-	// window, NOT the top row of the console.
-	cursorY int
+	// Whether the console extends to the bottom edge of the terminal, rather
+	// than having a fixed height.
+	fillHeight bool
 
 	// What text is printed as the prompt.
 	prompt string
 
-	// What cell row the prompt was most recently located at.
-	// Note that this is indexed from 0 starting with the top row of the terminal
-	// window, NOT the top row of the console.
-	promptY int
+	// What text is printed before each line of a command after the first.
+	contPrompt string
 
 	// The text of the current line of the console (what the user is actively
 	// editing).
 	currline string
 
+	// The offset into the current line that the cursor is located at. An
+	// offset of 0 places the cursor on the first character after the prompt.
+	cursor int
+
+	// Every line of output that has been completed, including past commands
+	// along with their prompts, up to scrollbackSize lines. Lines are stored
+	// unwrapped, so that they can be reflowed if the console is resized.
+	lines []string
+
+	// The formatting of each line of output, alongside lines. Lines printed
+	// without any formatting have no spans.
+	lineSpans [][]span
+
+	// The maximum number of completed lines of output to remember.
+	scrollbackSize int
+
+	// How many rows up from the current line the user has scrolled through
+	// past output. When 0, the console shows the current line.
+	scrollOffset int
+
+	// How many of the oldest lines of output were cleared from the screen, and
+	// are only shown if the user scrolls back to them.
+	clearedLines int
+
+	// The rows that the lines of output wrap into, kept so that they aren't
+	// wrapped again every time the console is drawn.
+	wrapped wrapCache
+
+	// Output which has been printed on the current line, but which has not yet
+	// been ended with a newline. The prompt is shown after it.
+	partial string
+
+	// The formatting of the output on the current line.
+	partialSpans []span
+
+	// The style that text given to Print is drawn in, as set by the escape
+	// sequences printed so far.
+	style Style
+
+	// The start of an escape sequence that was cut off at the end of the text
+	// last printed, held until the rest of it is printed.
+	escape string
+
+	// The offset into the output on the current line that the next output
+	// is written at. It is only before the end of the line after output moves
+	// back, such as with a carriage return, so that what follows overwrites it.
+	outCursor int
+
+	// Indicates whether a command is being executed, during which the prompt
+	// and current line are not shown, unless the command reads a line.
+	executing bool
+
 	// A user defined executer, used to process the actual commands sent to
 	// the console.
 	executer Executer
@@ -63,6 +136,61 @@ type Console struct {
 	// A user defined completer, used to provide suggestions for tab completion.
 	completer Completer
 
+	// Decides which action to perform when each key is pressed.
+	keymap *Keymap
+
+	// Keys pressed so far towards a sequence bound in the keymap.
+	pendingKeys []ugcli.Chord
+
+	// The key that triggered the action currently being performed.
+	lastKey ugcli.Chord
+
+	// The style of line editing in use.
+	editMode EditMode
+
+	// The state of the vi editing mode, when in use.
+	vi viState
+
+	// The state of the incremental history search, if one is in progress.
+	search searchState
+
+	// The state of the completion menu, if it is shown.
+	menu menuState
+
+	// The state of completion running in the background, if any.
+	async asyncState
+
+	// The state of a reader waiting for a line, if any.
+	read readState
+
+	// Indicates whether the console suggests how the current line might
+	// continue.
+	autosuggest bool
+
+	// A user defined autosuggester, used in place of the history to suggest
+	// how the current line might continue.
+	autosuggester Autosuggester
+
+	// The current line as it was when its continuation was last suggested,
+	// along with how many commands were in the history then, and the
+	// continuation suggested. suggestionSet is cleared to suggest it again.
+	suggested        string
+	suggestedHistory int
+	suggestion       string
+	suggestionSet    bool
+
+	// A user defined highlighter, used to format the current line.
+	highlighter Highlighter
+
+	// The current line as it was when the highlighter last formatted it, and
+	// the formatting it returned.
+	highlighted string
+	highlights  []span
+
+	// The queue the console receives events from, while it runs, so that
+	// work done in the background can wake it.
+	events *ugcli.EventQueue
+
 	// How many lines up into the previous commands buffer the user currently is.
 	// This is used when pressing the arrows to cycle through old commands.
 	diff int
@@ -72,37 +200,75 @@ type Console struct {
 	// typing can be restored if they come all the way back.
 	oldLineCopy string
 
-	// Holds up to bufferSize previous commands.
-	lineBuffer []string
+	// Stores previously executed commands.
+	history History
+
+	// Which commands are left out of the history.
+	historyFlags HistoryFlags
+
+	// Indicates whether the up/down arrows only cycle through commands
+	// starting with what the user had typed.
+	prefixSearch bool
+
+	// Holds up to killRingSize pieces of text that were killed from the current
+	// line, most recent last, so that they can be yanked back.
+	killRing []string
+
+	// Which entry of the kill ring was most recently yanked.
+	yankIdx int
 
-	// What index of the buffer the current line would be written into.
-	bufferIdx int
+	// The offset into the current line at which the most recently yanked text
+	// starts.
+	yankStart int
+
+	// What kind of editing command the previous key press performed.
+	prevEdit editKind
+
+	// What kind of editing command the current key press performed.
+	lastEdit editKind
 
 	// Indicates whether the console is actively running right now.
 	running bool
+
+	// Indicates whether the console currently has focus within the ugcli
+	// application, and so whether the cursor should be drawn.
+	focused bool
 }
 
 // New console will take the location and size of a console (in cells) and
 // return an appropriate console component.
 //
-// Note that top and left are 0-indexed.
+// Note that top and left are 0-indexed. A width or height of 0 indicates that
+// the console should extend to the right or bottom edge of the terminal.
+// Consoles placed by an application's layout take on whatever size they are
+// assigned, regardless.
 func NewConsole(top, left, width, height int) *Console {
 
 	return &Console{
-		top:         top,
-		left:        left,
-		width:       width,
-		height:      height,
-		cursorX:     left,
-		cursorY:     top,
-		promptY:     top,
-		prompt:      defaultPrompt,
-		currline:    "",
-		diff:        0,
-		oldLineCopy: "",
-		lineBuffer:  make([]string, bufferSize),
-		bufferIdx:   0,
-		running:     true,
+		top:            top,
+		left:           left,
+		width:          width,
+		height:         height,
+		fillWidth:      width <= 0,
+		fillHeight:     height <= 0,
+		prompt:         defaultPrompt,
+		contPrompt:     defaultContinuationPrompt,
+		keymap:         DefaultKeymap(),
+		editMode:       EmacsMode,
+		currline:       "",
+		cursor:         0,
+		lines:          []string{},
+		scrollbackSize: defaultScrollback,
+		scrollOffset:   0,
+		partial:        "",
+		diff:           0,
+		oldLineCopy:    "",
+		history:        NewRingHistory(bufferSize),
+		running:        true,
+		vi: viState{
+			insertIndicator: defaultViInsertIndicator,
+			normalIndicator: defaultViNormalIndicator,
+		},
 	}
 }
 
@@ -111,7 +277,46 @@ func (c *Console) SetExecuter(e Executer) {
 	c.executer = e
 }
 
+// SetContinuationPrompt sets the text shown before every line of a command
+// after the first, for commands which span several lines. See
+// MultiLineExecuter.
+func (c *Console) SetContinuationPrompt(prompt string) {
+	c.contPrompt = prompt
+}
+
 // SetCompleter attatches a user-defined tab completion object to the console.
 func (c *Console) SetCompleter(comp Completer) {
 	c.completer = comp
 }
+
+// Resize moves the console to the given location and size (in cells). Past
+// output and the current line are reflowed to fit the new width the next time
+// the console is drawn, keeping the cursor at the same place within the
+// current line.
+//
+// Resize is safe to use from any goroutine, but not from within a hook the
+// console calls while it is held, such as an Action or a Completer. Consoles
+// in a layout are resized by it instead.
+func (c *Console) Resize(top, left, width, height int) {
+	c.lock()
+	defer c.unlock()
+	c.resize(top, left, width, height)
+	// Should drawing fail, the console's loop reports the error when it next
+	// draws.
+	c.show()
+}
+
+// resize moves the console to the given location and size. The console must
+// be held.
+func (c *Console) resize(top, left, width, height int) {
+	c.top = top
+	c.left = left
+	c.width = width
+	c.height = height
+	if c.width < 1 {
+		c.width = 1
+	}
+	if c.height < 1 {
+		c.height = 1
+	}
+}
@@ -0,0 +1,164 @@
+package console
+
+// console_async.go contains asynchronous completion, which lets completers
+// that take a while, such as those querying a remote service, run in the
+// background while the user keeps typing.
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// completionLoading is shown below the cursor while waiting for a completer.
+const completionLoading = "loading..."
+
+// ContextCompleter is a Completer which may take a while to complete, so is
+// run in the background. While it runs, the console shows that completion is
+// loading and keeps accepting keys. If the line changes before it finishes,
+// its context is cancelled and its result is discarded. Consoles prefer
+// SuggestContext to all other methods of completion, when a completer
+// implements it.
+type ContextCompleter interface {
+	Completer
+
+	// SuggestContext is as SuggestionCompleter.Suggest, but is called in its
+	// own goroutine, and should give up once ctx is done. If it returns an
+	// error, the error is shown in place of the completion menu.
+	SuggestContext(ctx context.Context, req CompletionRequest) (prefix string, suggestions []Suggestion, err error)
+}
+
+// asyncState holds the state of a completion running in the background.
+type asyncState struct {
+
+	// Guards the result, which is filled in by the completer's goroutine.
+	mu sync.Mutex
+
+	// Indicates whether a completion is running.
+	pending bool
+
+	// Cancels the running completion's context.
+	cancel context.CancelFunc
+
+	// Counts completions started, so that a result can be matched with the
+	// completion it is for.
+	seq int
+
+	// The current line and cursor when the completion started, along with
+	// the offset into the line of the argument being completed and the quote
+	// it was typed in, if any.
+	line   string
+	cursor int
+	start  int
+	quote  byte
+
+	// The result of the most recent completion, once it has finished.
+	result *asyncResult
+
+	// The error from the most recent completion, if it failed, which is shown
+	// until the next key is pressed.
+	err error
+}
+
+// asyncResult holds the result of a completion run in the background.
+type asyncResult struct {
+	prefix      string
+	suggestions []Suggestion
+	err         error
+}
+
+// startCompletion starts running a completer in the background, cancelling
+// any completion already running.
+func (c *Console) startCompletion(comp ContextCompleter) {
+	c.cancelCompletion()
+	req, start, quote := c.completionRequest()
+	ctx, cancel := context.WithCancel(context.Background())
+
+	c.async.mu.Lock()
+	c.async.seq++
+	seq := c.async.seq
+	c.async.result = nil
+	c.async.mu.Unlock()
+
+	c.async.pending = true
+	c.async.cancel = cancel
+	c.async.line, c.async.cursor = c.currline, c.cursor
+	c.async.start, c.async.quote = start, quote
+	c.async.err = nil
+
+	events := c.events
+	go func() {
+		result := runCompleter(ctx, comp, req)
+		if ctx.Err() != nil {
+			return
+		}
+		c.async.mu.Lock()
+		if seq == c.async.seq {
+			c.async.result = result
+		}
+		c.async.mu.Unlock()
+		if events != nil {
+			events.Wake()
+		}
+	}()
+}
+
+// runCompleter calls comp.SuggestContext, turning a panic into an error, as
+// nothing else would recover from a panic in the completer's goroutine.
+func runCompleter(ctx context.Context, comp ContextCompleter, req CompletionRequest) (result *asyncResult) {
+	defer func() {
+		if r := recover(); r != nil {
+			result = &asyncResult{err: fmt.Errorf("console: completer panicked: %v", r)}
+		}
+	}()
+	prefix, suggestions, err := comp.SuggestContext(ctx, req)
+	return &asyncResult{prefix, suggestions, err}
+}
+
+// checkCompletion is called after every event. If the line has changed since
+// the running completion started, the completion is cancelled. Otherwise, if
+// it has finished, its result is applied.
+func (c *Console) checkCompletion() {
+	if !c.async.pending {
+		return
+	} else if c.currline != c.async.line || c.cursor != c.async.cursor {
+		c.cancelCompletion()
+		return
+	}
+
+	c.async.mu.Lock()
+	result := c.async.result
+	c.async.result = nil
+	c.async.mu.Unlock()
+	if result == nil {
+		return
+	}
+
+	c.async.pending = false
+	c.async.cancel()
+	if result.err != nil {
+		c.async.err = result.err
+		return
+	}
+	c.applyCompletion(result.prefix, result.suggestions, c.async.start,
+		c.async.quote)
+}
+
+// cancelCompletion cancels the running completion, if any.
+func (c *Console) cancelCompletion() {
+	if c.async.pending {
+		c.async.pending = false
+		c.async.cancel()
+	}
+}
+
+// completionStatus returns what to show below the cursor about a completion
+// running in the background, or "" if there is nothing to show.
+func (c *Console) completionStatus() string {
+	if c.async.pending {
+		return completionLoading
+	} else if c.async.err != nil {
+		return c.async.err.Error()
+	}
+	return ""
+}
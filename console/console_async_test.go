@@ -0,0 +1,216 @@
+package console
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// gatedCompleter is a ContextCompleter which reports each completion's
+// context as it starts, then waits for its result to be sent, or for the
+// context to be cancelled.
+type gatedCompleter struct {
+	started chan context.Context
+	results chan asyncResult
+}
+
+func newGatedCompleter() *gatedCompleter {
+	return &gatedCompleter{
+		started: make(chan context.Context, 1),
+		results: make(chan asyncResult, 1),
+	}
+}
+
+func (gc *gatedCompleter) Complete(input string) (string, []string) {
+	return input, []string{}
+}
+
+func (gc *gatedCompleter) SuggestContext(ctx context.Context, req CompletionRequest) (string, []Suggestion, error) {
+	gc.started <- ctx
+	select {
+	case r := <-gc.results:
+		return r.prefix, r.suggestions, r.err
+	case <-ctx.Done():
+		return "", nil, ctx.Err()
+	}
+}
+
+// waitStarted waits for the completer to be called, returning its context.
+func (gc *gatedCompleter) waitStarted(t *testing.T) context.Context {
+	t.Helper()
+	select {
+	case ctx := <-gc.started:
+		return ctx
+	case <-time.After(5 * time.Second):
+		t.Fatal("completer never started")
+		return nil
+	}
+}
+
+// waitResult waits for the console's background completion to finish.
+func waitResult(t *testing.T, c *Console) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		c.async.mu.Lock()
+		done := c.async.result != nil
+		c.async.mu.Unlock()
+		if done {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("completion never finished")
+}
+
+func TestAsyncCompletion(t *testing.T) {
+	gc := newGatedCompleter()
+	c := NewConsole(0, 0, 80, 10)
+	c.SetCompleter(gc)
+	typeText(t, c, "s")
+	pressKeys(t, c, "Tab")
+	gc.waitStarted(t)
+	if status := c.completionStatus(); status != completionLoading {
+		t.Errorf("status while running = %q, want %q", status, completionLoading)
+	}
+
+	gc.results <- asyncResult{"st", []Suggestion{{Text: "stash"}, {Text: "status"}}, nil}
+	waitResult(t, c)
+	c.checkCompletion()
+	checkLine(t, c, "st", 2)
+	if !c.menu.active || len(c.menu.suggestions) != 2 {
+		t.Errorf("menu = %+v, want 2 suggestions", c.menu)
+	}
+	if status := c.completionStatus(); status != "" {
+		t.Errorf("status once finished = %q, want none", status)
+	}
+}
+
+func TestAsyncCompletionCancel(t *testing.T) {
+	gc := newGatedCompleter()
+	c := NewConsole(0, 0, 80, 10)
+	c.SetCompleter(gc)
+	typeText(t, c, "s")
+	pressKeys(t, c, "Tab")
+	ctx := gc.waitStarted(t)
+
+	// Changing the line cancels the completion.
+	typeText(t, c, "t")
+	c.checkCompletion()
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("completion wasn't cancelled")
+	}
+	if status := c.completionStatus(); status != "" {
+		t.Errorf("status once cancelled = %q, want none", status)
+	}
+	checkLine(t, c, "st", 2)
+}
+
+func TestAsyncCompletionStale(t *testing.T) {
+	gc := newGatedCompleter()
+	c := NewConsole(0, 0, 80, 10)
+	c.SetCompleter(gc)
+	typeText(t, c, "s")
+	pressKeys(t, c, "Tab")
+	gc.waitStarted(t)
+
+	// A result for a completion which has been replaced is discarded, even if
+	// its completer ignores its context.
+	c.async.mu.Lock()
+	seq := c.async.seq
+	c.async.mu.Unlock()
+	pressKeys(t, c, "Tab")
+	gc.waitStarted(t)
+	c.async.mu.Lock()
+	if c.async.seq != seq+1 || c.async.result != nil {
+		t.Errorf("seq = %d, result %v, want %d, none", c.async.seq, c.async.result, seq+1)
+	}
+	c.async.mu.Unlock()
+
+	gc.results <- asyncResult{"sh", []Suggestion{{Text: "show"}, {Text: "shift"}}, nil}
+	waitResult(t, c)
+	c.checkCompletion()
+	checkLine(t, c, "sh", 2)
+}
+
+func TestAsyncCompletionError(t *testing.T) {
+	gc := newGatedCompleter()
+	c := NewConsole(0, 0, 80, 10)
+	c.SetCompleter(gc)
+	pressKeys(t, c, "Tab")
+	gc.waitStarted(t)
+	gc.results <- asyncResult{err: errors.New("offline")}
+	waitResult(t, c)
+	c.checkCompletion()
+	if status := c.completionStatus(); status != "offline" {
+		t.Errorf("status after failing = %q, want %q", status, "offline")
+	}
+
+	// The error is shown until the next key is pressed.
+	typeText(t, c, "x")
+	if status := c.completionStatus(); status != "" {
+		t.Errorf("status after a key = %q, want none", status)
+	}
+}
+
+// panickingCompleter is a ContextCompleter which panics.
+type panickingCompleter struct{}
+
+func (panickingCompleter) Complete(input string) (string, []string) {
+	return input, []string{}
+}
+
+func (panickingCompleter) SuggestContext(ctx context.Context, req CompletionRequest) (string, []Suggestion, error) {
+	panic("no network")
+}
+
+func TestAsyncCompletionPanic(t *testing.T) {
+	c := NewConsole(0, 0, 80, 10)
+	c.SetCompleter(panickingCompleter{})
+	pressKeys(t, c, "Tab")
+	waitResult(t, c)
+	c.checkCompletion()
+	want := "console: completer panicked: no network"
+	if status := c.completionStatus(); status != want {
+		t.Errorf("status after panicking = %q, want %q", status, want)
+	}
+}
+
+func TestAsyncCommandCompleter(t *testing.T) {
+	if _, ok := newDeployCompleter().(ContextCompleter); ok {
+		t.Error("command completer without a ContextCompleter is a ContextCompleter")
+	}
+
+	// A command completer is run in the background if any of its arguments
+	// are, and passes its context on.
+	gc := newGatedCompleter()
+	comp := NewCommandCompleter(&Command{Name: "deploy", Args: []Completer{gc}})
+	if _, ok := comp.(ContextCompleter); !ok {
+		t.Fatal("command completer with a ContextCompleter isn't a ContextCompleter")
+	}
+	c := NewConsole(0, 0, 80, 10)
+	c.SetCompleter(comp)
+	typeText(t, c, "deploy s")
+	pressKeys(t, c, "Tab")
+	ctx := gc.waitStarted(t)
+	if status := c.completionStatus(); status != completionLoading {
+		t.Errorf("status while running = %q, want %q", status, completionLoading)
+	}
+	typeText(t, c, "t")
+	c.checkCompletion()
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("argument completion wasn't cancelled")
+	}
+
+	pressKeys(t, c, "Tab")
+	gc.waitStarted(t)
+	gc.results <- asyncResult{"staging", []Suggestion{{Text: "staging"}}, nil}
+	waitResult(t, c)
+	c.checkCompletion()
+	checkLine(t, c, "deploy staging", 14)
+}
@@ -0,0 +1,133 @@
+package console
+
+// console_autosuggest.go contains autosuggestions, which show how the line
+// the user is typing might continue, in dimmed text after the cursor, as in
+// the fish shell.
+
+import (
+	"strings"
+
+	tb "github.com/nsf/termbox-go"
+)
+
+// autosuggestFmt is used in drawing the suggested continuation of the line.
+const autosuggestFmt = tb.ColorDefault | tb.AttrDim
+
+// Autosuggester proposes how the line the user is typing might continue.
+type Autosuggester interface {
+
+	// Autosuggest returns the text which might follow the given line, or ""
+	// if there is nothing to suggest.
+	Autosuggest(line string) string
+}
+
+// SetAutosuggest sets whether the console suggests how the line might
+// continue while the user types it. By default, the most recent command in
+// the history starting with the line is suggested, unless an Autosuggester
+// has been set. Suggestions are accepted with the right arrow or End, or a
+// word at a time with Alt-F.
+func (c *Console) SetAutosuggest(enabled bool) {
+	c.autosuggest = enabled
+	c.suggestionSet = false
+}
+
+// SetAutosuggester sets what suggests how the line might continue, enabling
+// autosuggestions. A nil Autosuggester goes back to suggesting commands from
+// the history.
+func (c *Console) SetAutosuggester(s Autosuggester) {
+	c.autosuggester = s
+	c.autosuggest = true
+	c.suggestionSet = false
+}
+
+// autosuggestion returns the suggested continuation of the current line, if
+// one should be shown. Suggestions are only shown while the cursor is at the
+// end of the line, and stop at the end of the line they continue.
+func (c *Console) autosuggestion() string {
+	if !c.autosuggest || c.currline == "" || c.cursor != len(c.currline) ||
+		!c.editing() || c.search.active || c.menu.active {
+		return ""
+	}
+
+	c.suggest()
+	return c.suggestion
+}
+
+// suggest asks for the suggested continuation of the current line, if either
+// the line or the history has changed since it was last suggested.
+func (c *Console) suggest() {
+	if c.suggestionSet && c.suggested == c.currline &&
+		c.suggestedHistory == c.history.Len() {
+		return
+	}
+	c.suggested, c.suggestedHistory = c.currline, c.history.Len()
+	c.suggestionSet = true
+
+	if c.autosuggester != nil {
+		c.suggestion = c.autosuggester.Autosuggest(c.currline)
+	} else {
+		c.suggestion = c.historySuggestion()
+	}
+	if i := strings.Index(c.suggestion, "\n"); i >= 0 {
+		c.suggestion = c.suggestion[:i]
+	}
+}
+
+// historySuggestion returns the rest of the most recent command in the history
+// which starts with the current line, or "" if there is none.
+func (c *Console) historySuggestion() string {
+	for i := c.history.Len() - 1; i >= 0; i-- {
+		entry := c.history.Entry(i)
+		if len(entry) > len(c.currline) && strings.HasPrefix(entry, c.currline) {
+			return entry[len(c.currline):]
+		}
+	}
+	return ""
+}
+
+// acceptSuggestion places the suggested continuation of the line into it, or
+// just its next word if word is set, returning false if there was nothing to
+// accept.
+func (c *Console) acceptSuggestion(word bool) bool {
+	suggestion := c.autosuggestion()
+	if suggestion == "" {
+		return false
+	}
+	if word {
+		// Take up to the end of the next word, as wordForward would.
+		i := 0
+		for i < len(suggestion) && !isWordChar(suggestion, i) {
+			i = nextBoundary(suggestion, i)
+		}
+		for i < len(suggestion) && isWordChar(suggestion, i) {
+			i = nextBoundary(suggestion, i)
+		}
+		suggestion = suggestion[:i]
+	}
+	c.Insert(suggestion)
+	return true
+}
+
+// forwardChar moves the cursor one character to the right, or accepts the
+// suggested continuation of the line at its end.
+func (c *Console) forwardChar() {
+	if !c.acceptSuggestion(false) {
+		c.moveCursorRight()
+	}
+}
+
+// forwardWord moves the cursor to the end of the next word, or accepts the
+// next word of the suggested continuation of the line at its end.
+func (c *Console) forwardWord() {
+	if !c.acceptSuggestion(true) {
+		c.wordForward()
+	}
+}
+
+// endOfLine moves the cursor to the end of the line, or accepts the suggested
+// continuation of the line if it is already there.
+func (c *Console) endOfLine() {
+	if !c.acceptSuggestion(false) {
+		c.moveToEnd()
+	}
+}
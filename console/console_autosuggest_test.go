@@ -0,0 +1,105 @@
+package console
+
+import "testing"
+
+// suffixSuggester suggests the same continuation for every line.
+type suffixSuggester string
+
+func (s suffixSuggester) Autosuggest(line string) string {
+	return string(s)
+}
+
+func TestHistoryAutosuggest(t *testing.T) {
+	c := NewConsole(0, 0, 80, 10)
+	for _, line := range []string{"git commit -m fix", "git status", "ls"} {
+		c.History().Add(line)
+	}
+	typeText(t, c, "git")
+	if got := c.autosuggestion(); got != "" {
+		t.Errorf("suggestion while disabled = %q, want none", got)
+	}
+
+	c.SetAutosuggest(true)
+	if got, want := c.autosuggestion(), " status"; got != want {
+		t.Errorf("suggestion for %q = %q, want %q", c.currline, got, want)
+	}
+	typeText(t, c, " c")
+	if got, want := c.autosuggestion(), "ommit -m fix"; got != want {
+		t.Errorf("suggestion for %q = %q, want %q", c.currline, got, want)
+	}
+
+	// Suggestions are only shown with the cursor at the end of the line.
+	pressKeys(t, c, "Left")
+	if got := c.autosuggestion(); got != "" {
+		t.Errorf("suggestion with the cursor inside the line = %q, want none", got)
+	}
+	pressKeys(t, c, "Right")
+
+	// Alt-F accepts a word, and the right arrow accepts the rest.
+	pressKeys(t, c, "M-f")
+	checkLine(t, c, "git commit", 10)
+	pressKeys(t, c, "M-f")
+	checkLine(t, c, "git commit -m", 13)
+	pressKeys(t, c, "Right")
+	checkLine(t, c, "git commit -m fix", 17)
+
+	// With nothing to accept, the keys move the cursor as usual.
+	pressKeys(t, c, "C-a Right")
+	checkLine(t, c, "git commit -m fix", 1)
+	pressKeys(t, c, "End")
+	checkLine(t, c, "git commit -m fix", 17)
+}
+
+func TestAutosuggester(t *testing.T) {
+	c := NewConsole(0, 0, 80, 10)
+	c.SetAutosuggester(suffixSuggester(" --all\nmore"))
+	typeText(t, c, "ls")
+
+	// Suggestions stop at the end of the line they continue.
+	if got, want := c.autosuggestion(), " --all"; got != want {
+		t.Errorf("suggestion = %q, want %q", got, want)
+	}
+	pressKeys(t, c, "End")
+	checkLine(t, c, "ls --all", 8)
+}
+
+// countingSuggester suggests nothing, counting how many lines it was asked to
+// continue.
+type countingSuggester struct {
+	calls int
+}
+
+func (s *countingSuggester) Autosuggest(line string) string {
+	s.calls++
+	return ""
+}
+
+func TestAutosuggestCached(t *testing.T) {
+	s := &countingSuggester{}
+	c := NewConsole(0, 0, 80, 10)
+	c.SetAutosuggester(s)
+	typeText(t, c, "ls")
+	c.autosuggestion()
+	calls := s.calls
+	c.autosuggestion()
+	c.wrapRows()
+	c.wrapRows()
+	if s.calls != calls {
+		t.Errorf("unchanged line suggested %d more times", s.calls-calls)
+	}
+	typeText(t, c, " ")
+	c.autosuggestion()
+	if s.calls != calls+1 {
+		t.Errorf("changed line suggested %d times, want once", s.calls-calls)
+	}
+
+	// Suggestions from the history change as commands are added to it.
+	c.SetAutosuggester(nil)
+	if got := c.autosuggestion(); got != "" {
+		t.Errorf("suggestion from an empty history = %q, want none", got)
+	}
+	c.History().Add("ls -l")
+	if got, want := c.autosuggestion(), "-l"; got != want {
+		t.Errorf("suggestion once added = %q, want %q", got, want)
+	}
+}
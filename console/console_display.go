@@ -4,187 +4,256 @@ package console
 // console elements into the terminal.
 
 import (
+	"strings"
+
 	tb "github.com/nsf/termbox-go"
+
+	"github.com/mcprice30/ugcli"
 )
 
 // cursorFmt is used in setting the color of the cursor itself or any text that
 // the cursor is hovering over.
 const cursorFmt = tb.ColorDefault | tb.AttrReverse
 
-// incrementCursor will move the cursor one cell to the right, scrolling the
-// screen if necessary, but without redrawing the cursor.
-func (c *Console) incrementCursor() {
-	c.cursorX++
-	c.cursorY += c.cursorX / c.width
-	c.cursorX = c.cursorX % c.width
-	if c.cursorY >= c.top+c.height {
-		c.scrollDown()
+// draw renders the console from its model and flushes it to the terminal:
+// past output, followed by the prompt and the current line, with the cursor
+// drawn if the console has focus. If there is more output than fits, only the
+// most recent rows are shown, unless the user has scrolled back through the
+// output.
+//
+// The rows are worked out before the screen is held, so that other components
+// only wait for the console while it paints them, not while hooks such as the
+// highlighter run. Nothing is drawn once the application has stopped.
+func (c *Console) draw() error {
+	c.highlight()
+	rows, cursorRow, cursorCol := c.wrapRows()
+	first := c.firstRow(len(rows), cursorRow)
+
+	ugcli.LockScreen()
+	defer ugcli.UnlockScreen()
+	if !ugcli.ScreenOpen() {
+		return nil
 	}
+	c.render(rows[first:], cursorRow-first, cursorCol)
+	return tb.Flush()
 }
 
-// decrementCursor will move the cursor one cell to the left, scrolling the
-// screen if necessary, but without redrawing the cursor.
-func (c *Console) decrementCursor() {
-	c.cursorX--
-	if c.cursorX < 0 {
-		c.cursorY--
-		c.cursorX = c.left + c.width
+// render paints the given rows into the console's rectangle of the terminal,
+// from its top, along with the cursor, at the given row and column counted
+// from the top left of the console. Nothing is painted outside of the
+// console's rectangle.
+//
+// Everything the console shows is painted by render, so it can always be
+// repainted from its state, such as after it is resized or regains focus.
+func (c *Console) render(rows []displayRow, cursorRow, cursorCol int) {
+	for y := c.top; y < c.top+c.height; y++ {
+		for x := c.left; x < c.left+c.width; x++ {
+			c.setCell(x, y, ' ', tb.ColorDefault, tb.ColorDefault)
+		}
 	}
-}
 
-// moveCursorLeft will move the cursor one cell to the left (if possible) and
-// will redraw the cursor image.
-func (c *Console) moveCursorLeft() {
-	if loc := c.getCursorLoc(); loc == 0 {
-		return
+	for i, row := range rows {
+		if i >= c.height {
+			break
+		}
+		x := c.left
+		for j := 0; j < len(row.text); {
+			next := nextBoundary(row.text, j)
+			fg, bg := spanFmt(row.spans, row.at+j)
+			if row.live && j >= row.lead {
+				fg, bg = c.lineFmt(row.offset + j)
+			}
+			c.setCell(x, c.top+i, firstRune(row.text[j:next]), fg, bg)
+			x += clusterWidth(row.text[j:next])
+			j = next
+		}
 	}
-	tb.SetCell(c.cursorX, c.cursorY, c.getCursorChar(), tb.ColorDefault, tb.ColorDefault)
-	c.decrementCursor()
-	tb.SetCell(c.cursorX, c.cursorY, c.getCursorChar(), cursorFmt, cursorFmt)
-}
 
-// moveCursorLeft will move the cursor one cell to the right (if possible) and
-// will redraw the cursor image.
-func (c *Console) moveCursorRight() {
-	if loc := c.getCursorLoc(); loc >= len(c.currline) {
-		return
+	if c.menu.active && c.editing() && c.scrollOffset == 0 {
+		c.drawMenu(cursorRow)
+	} else if status := c.completionStatus(); status != "" && c.editing() &&
+		c.scrollOffset == 0 {
+		c.drawStatus(cursorRow, status)
+	}
+	if c.focused && c.editing() && c.scrollOffset == 0 {
+		c.setCell(c.left+cursorCol, c.top+cursorRow, c.getCursorChar(),
+			cursorFmt, cursorFmt)
 	}
-	tb.SetCell(c.cursorX, c.cursorY, c.getCursorChar(), tb.ColorDefault, tb.ColorDefault)
-	c.incrementCursor()
-	tb.SetCell(c.cursorX, c.cursorY, c.getCursorChar(), cursorFmt, cursorFmt)
 }
 
-// getCursorLoc will return the offset into the line that the cursor is at.
-// The prompt is not included, meaning that the first cell AFTER the prompt
-// will have an offset of 0.
-func (c *Console) getCursorLoc() int {
-	return (c.cursorY-c.promptY)*c.width + c.cursorX - c.left - len(c.prompt)
-}
+// firstRow returns the first of the given number of rows to show. When the
+// console isn't scrolled back, the most recent rows are shown, other than any
+// cleared from the screen, and the row the cursor is on is always among them,
+// even if the current line has more rows than fit.
+func (c *Console) firstRow(rows, cursorRow int) int {
 
-// getCursorChar returns the character currently underneath the cursor.
-func (c *Console) getCursorChar() rune {
-	if loc := c.getCursorLoc(); loc < 0 || loc >= len(c.currline) {
-		return ' '
-	} else {
-		return rune(c.currline[loc])
+	// live is the first row shown when the console isn't scrolled back. Any
+	// rows that were cleared from the screen are left above it.
+	live := 0
+	if rows > c.height {
+		live = rows - c.height
+	}
+	if _, cleared := c.outputRows(); cleared > live {
+		live = cleared
+	}
+	if cursorRow >= live+c.height {
+		live = cursorRow - c.height + 1
+	} else if cursorRow < live {
+		live = cursorRow
 	}
-}
 
-// writeChar will write a character where the cursor is. It will NOT shift
-// any characters that occur after it on the line it is on to the right.
-func (c *Console) writeChar(ch rune) {
-	tb.SetCell(c.cursorX, c.cursorY, ch, tb.ColorDefault, tb.ColorDefault)
-	c.incrementCursor()
-	tb.SetCell(c.cursorX, c.cursorY, ' ', tb.ColorWhite, tb.ColorWhite)
+	if c.scrollOffset > live {
+		c.scrollOffset = live
+	}
+	return live - c.scrollOffset
 }
 
-// insertChar will write a character where the cursor is, shifting all
-// characters that occur after it on the line it is on to the right.
-func (c *Console) insertChar(ch rune) {
-	loc := c.getCursorLoc()
-	cX := c.cursorX
-	cY := c.cursorY
-	// Remove cursor box from image.
-	tb.SetCell(cX, cY, ch, tb.ColorDefault, tb.ColorDefault)
-	// Move cursor to the right.
-	c.incrementCursor()
-
-	// Shift all later cells to the right.
-	for i := loc; i < len(c.currline); i++ {
-		tb.SetCell(c.cursorX, c.cursorY, rune(c.currline[i]), tb.ColorDefault, tb.ColorDefault)
-		c.incrementCursor()
-	}
-
-	// Move the cursor back to where it was.
-	c.cursorX = cX
-	c.cursorY = cY
-	// Update the current line.
-	c.currline = c.currline[:loc] + string(ch) + c.currline[loc:]
-	// Shift the cursor one over to be over the character AFTER the one
-	// that was just inserted.
-	c.moveCursorRight()
+// setCell paints a single cell of the terminal, as tb.SetCell does, unless it
+// lies outside of the console's rectangle.
+func (c *Console) setCell(x, y int, ch rune, fg, bg tb.Attribute) {
+	if x < c.left || x >= c.left+c.width || y < c.top || y >= c.top+c.height {
+		return
+	}
+	tb.SetCell(x, y, ch, fg, bg)
 }
 
-// Print prints a string, with no newline, to a given Console.
-// This will have strange behavior unless called when the cursor is at the
-// end of the current line.
-func (c *Console) Print(str string) {
-	for _, ch := range str {
-		c.writeChar(ch)
-	}
+// editing indicates whether the prompt and current line are shown, which
+// they are unless a command is being executed. A command may still read a
+// line from the user, though.
+func (c *Console) editing() bool {
+	return !c.executing || c.read.pending
 }
 
-// Println prints a string, followed by a newline, to a given Console.
-// This will have strange behavior unless called when the cursor is at the
-// end of the current line.
-func (c *Console) Println(str string) {
-	c.Print(str)
-	tb.SetCell(c.cursorX, c.cursorY, ' ', tb.ColorDefault, tb.ColorDefault)
-	c.cursorX = c.left
-	c.cursorY++
-	if c.cursorY >= c.top+c.height {
-		c.scrollDown()
-	}
-	tb.SetCell(c.cursorX, c.cursorY, ' ', tb.ColorWhite, tb.ColorWhite)
+// displayRow is a single row of the console, as it is shown.
+type displayRow struct {
+
+	// The text shown in the row.
+	text string
+
+	// The formatting of the line of output that the row is part of, and the
+	// offset into that line that the row starts at.
+	spans []span
+	at    int
+
+	// Indicates whether the row shows part of the current line.
+	live bool
+
+	// For rows showing the current line, the offset into the current line
+	// that the row's text corresponds to, were the text not to start with a
+	// prompt.
+	offset int
+
+	// How many bytes at the start of the row's text are a prompt, or output,
+	// rather than part of the current line.
+	lead int
 }
 
-// backspace performs the equivalent of pressing the backspace key.
-// Moves the cursor one cell back, then deletes the value under the cursor.
-func (c *Console) backspace() {
-	loc := c.getCursorLoc()
-	if loc <= 0 {
-		return
+// wrapRows breaks every line of output, followed by the current line, into
+// rows no wider than the console. Each line of the current line is shown
+// after the prompt, or the continuation prompt for all but the first. It also
+// returns the row and column that the cursor is located at, leaving room for
+// the cursor to sit just past the end of a line, on a row of its own.
+func (c *Console) wrapRows() (rows []displayRow, cursorRow, cursorCol int) {
+	rows, _ = c.outputRows()
+
+	if !c.editing() {
+		cursorRow = len(rows)
+		return appendOutput(rows, c.partial, c.partialSpans, c.width),
+			cursorRow, 0
 	}
 
-	cX := c.cursorX
-	cY := c.cursorY
+	lead := c.partial + c.promptText()
+	spans := c.partialSpans
+	start := 0
+	lines := strings.Split(c.currline, "\n")
+	for i, line := range lines {
+		text := lead + line
+		if i == len(lines)-1 {
+			text += c.autosuggestion()
+		}
+		lineRow := len(rows)
+		at := 0
+		for _, row := range wrapLine(text, c.width) {
+			n := len(lead) - at
+			if n < 0 {
+				n = 0
+			} else if n > len(row) {
+				n = len(row)
+			}
+			rows = append(rows, displayRow{
+				text:   row,
+				spans:  spans,
+				at:     at,
+				live:   true,
+				offset: start + at - len(lead),
+				lead:   n,
+			})
+			at += len(row)
+		}
 
-	for i := loc; i+1 < len(c.currline); i++ {
-		tb.SetCell(c.cursorX, c.cursorY, rune(c.currline[i+1]), tb.ColorDefault, tb.ColorDefault)
-		c.incrementCursor()
-	}
-	tb.SetCell(c.cursorX, c.cursorY, ' ', tb.ColorDefault, tb.ColorDefault)
+		// Text before the cursor wraps just as it does within the whole line,
+		// so the cursor follows the last row of it, unless the character under
+		// the cursor doesn't fit there.
+		if c.cursor >= start && c.cursor <= start+len(line) {
+			before := wrapLine(text[:len(lead)+c.cursor-start], c.width)
+			cursorRow = lineRow + len(before) - 1
+			cursorCol = textWidth(before[len(before)-1])
+			if cursorCol > 0 && cursorCol+c.cursorWidth() > c.width {
+				cursorRow, cursorCol = cursorRow+1, 0
+			}
+			if cursorRow >= len(rows) {
+				rows = append(rows, displayRow{live: true})
+			}
+		}
 
-	if loc == 1 {
-		c.currline = c.currline[loc:]
-	} else if loc == len(c.currline) {
-		c.currline = c.currline[:loc-1]
-	} else {
-		c.currline = c.currline[:loc-1] + c.currline[loc:]
+		lead, spans = c.contPrompt, nil
+		start += len(line) + 1
 	}
-
-	c.cursorX = cX
-	c.cursorY = cY
-	c.moveCursorLeft()
+	return rows, cursorRow, cursorCol
 }
 
-// clearLine clears all text to the right of the current line's prompt string.
-func (c *Console) clearLine() {
-	for _ = range c.currline {
-		c.backspace()
+// appendOutput breaks a line of output, with the given formatting, into rows
+// no wider than width, appending them to rows.
+func appendOutput(rows []displayRow, line string, spans []span, width int) []displayRow {
+	at := 0
+	for _, text := range wrapLine(line, width) {
+		rows = append(rows, displayRow{text: text, spans: spans, at: at})
+		at += len(text)
 	}
-	c.currline = ""
+	return rows
 }
 
-// Scroll down one cell on the console.
-func (c *Console) scrollDown() {
-	buffer := tb.CellBuffer()
-	tbWidth, _ := tb.Size()
-
-	for y := c.top; y < c.top+c.height-1; y++ {
-		for x := c.left; x < c.left+c.width; x++ {
-			oldCell := buffer[(y+1)*tbWidth+x]
-			tb.SetCell(x, y, oldCell.Ch, oldCell.Fg, oldCell.Bg)
-		}
+// lineFmt returns the foreground and background attributes of the text at the
+// given offset into the current line, as formatted by the highlighter. Offsets
+// past its end are part of its autosuggestion.
+func (c *Console) lineFmt(offset int) (fg, bg tb.Attribute) {
+	if offset >= len(c.currline) {
+		return autosuggestFmt, tb.ColorDefault
 	}
+	if start, end := c.searchMatch(); offset >= start && offset < end {
+		return searchMatchFmt, tb.ColorDefault
+	}
+	return spanFmt(c.highlights, offset)
+}
 
-	for x := c.left; x < c.left+c.width; x++ {
-		tb.SetCell(x, c.top+c.height-1, ' ', tb.ColorDefault, tb.ColorDefault)
+// getCursorChar returns the character currently underneath the cursor.
+func (c *Console) getCursorChar() rune {
+	if c.cursor >= len(c.currline) {
+		if suggestion := c.autosuggestion(); suggestion != "" {
+			return firstRune(suggestion)
+		}
+		return ' '
+	} else if c.currline[c.cursor] == '\n' {
+		return ' '
 	}
+	return firstRune(c.currline[c.cursor:nextBoundary(c.currline, c.cursor)])
+}
 
-	c.cursorY--
-	c.promptY--
-	if c.cursorY < 0 {
-		c.cursorY = 0
+// cursorWidth returns the number of cells taken up by the character currently
+// underneath the cursor.
+func (c *Console) cursorWidth() int {
+	if c.cursor >= len(c.currline) {
+		return 1
 	}
+	return clusterWidth(c.currline[c.cursor:nextBoundary(c.currline, c.cursor)])
 }
@@ -0,0 +1,260 @@
+package console
+
+// console_editing.go contains the emacs-style line editing commands that the
+// console supports, in the manner of readline. Like the operations in
+// console_model.go, they only edit the console's model.
+
+import (
+	"strings"
+	"unicode"
+)
+
+// killRingSize indicates the maximum number of killed pieces of text to
+// remember for yanking back.
+const killRingSize = 10
+
+// editKind classifies an editing command, so that a command can tell what the
+// one before it did.
+type editKind int
+
+const (
+	// editOther is any command that doesn't need to be remembered.
+	editOther editKind = iota
+
+	// editKill is a command that killed text into the kill ring.
+	editKill
+
+	// editYank is a command that yanked text from the kill ring.
+	editYank
+)
+
+// moveToStart moves the cursor to the start of the current line.
+func (c *Console) moveToStart() {
+	c.cursor = 0
+}
+
+// moveToEnd moves the cursor to the end of the current line.
+func (c *Console) moveToEnd() {
+	c.cursor = len(c.currline)
+}
+
+// deleteForward deletes the character underneath the cursor.
+func (c *Console) deleteForward() {
+	next := nextBoundary(c.currline, c.cursor)
+	c.currline = c.currline[:c.cursor] + c.currline[next:]
+}
+
+// transposeChars swaps the character before the cursor with the one under it,
+// then moves the cursor forward. At the end of the line, the last two
+// characters are swapped instead.
+func (c *Console) transposeChars() {
+	if c.cursor == 0 || len(c.currline) == 0 {
+		return
+	}
+	if c.cursor == len(c.currline) {
+		c.cursor = prevBoundary(c.currline, c.cursor)
+		if c.cursor == 0 {
+			return
+		}
+	}
+
+	prev := prevBoundary(c.currline, c.cursor)
+	next := nextBoundary(c.currline, c.cursor)
+	c.currline = c.currline[:prev] + c.currline[c.cursor:next] +
+		c.currline[prev:c.cursor] + c.currline[next:]
+	c.cursor = next
+}
+
+// wordBackward moves the cursor back to the start of the current or previous
+// word. Words are made up of letters and digits.
+func (c *Console) wordBackward() {
+	c.cursor = c.wordStart(c.cursor)
+}
+
+// wordForward moves the cursor forward to the end of the current or next
+// word.
+func (c *Console) wordForward() {
+	c.cursor = c.wordEnd(c.cursor)
+}
+
+// wordStart returns the offset of the start of the word before offset i,
+// skipping any non-word characters in between.
+func (c *Console) wordStart(i int) int {
+	for i > 0 && !isWordChar(c.currline, prevBoundary(c.currline, i)) {
+		i = prevBoundary(c.currline, i)
+	}
+	for i > 0 && isWordChar(c.currline, prevBoundary(c.currline, i)) {
+		i = prevBoundary(c.currline, i)
+	}
+	return i
+}
+
+// wordEnd returns the offset of the end of the word after offset i, skipping
+// any non-word characters in between.
+func (c *Console) wordEnd(i int) int {
+	for i < len(c.currline) && !isWordChar(c.currline, i) {
+		i = nextBoundary(c.currline, i)
+	}
+	for i < len(c.currline) && isWordChar(c.currline, i) {
+		i = nextBoundary(c.currline, i)
+	}
+	return i
+}
+
+// isWordChar reports whether the character at offset i of str belongs to a
+// word.
+func isWordChar(str string, i int) bool {
+	r := firstRune(str[i:])
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// killToEnd kills all text from the cursor to the end of the line.
+func (c *Console) killToEnd() {
+	c.kill(c.cursor, len(c.currline))
+}
+
+// killToStart kills all text from the start of the line to the cursor.
+func (c *Console) killToStart() {
+	c.kill(0, c.cursor)
+}
+
+// killWordBackward kills the text between the cursor and the previous
+// whitespace, as readline's unix-word-rubout does.
+func (c *Console) killWordBackward() {
+	start := c.cursor
+	for start > 0 && isSpaceChar(c.currline, prevBoundary(c.currline, start)) {
+		start = prevBoundary(c.currline, start)
+	}
+	for start > 0 && !isSpaceChar(c.currline, prevBoundary(c.currline, start)) {
+		start = prevBoundary(c.currline, start)
+	}
+	c.kill(start, c.cursor)
+}
+
+// killWordForward kills the text from the cursor to the end of the next word.
+func (c *Console) killWordForward() {
+	c.kill(c.cursor, c.wordEnd(c.cursor))
+}
+
+// isSpaceChar reports whether the character at offset i of str is whitespace.
+func isSpaceChar(str string, i int) bool {
+	return unicode.IsSpace(firstRune(str[i:]))
+}
+
+// kill removes the text between offsets start and end of the current line,
+// saving it to the kill ring. Text killed by consecutive kill commands is
+// collected into a single entry, so that it can be yanked back all at once.
+func (c *Console) kill(start, end int) {
+	if start >= end {
+		c.lastEdit = editKill
+		return
+	}
+
+	text := c.currline[start:end]
+	if c.prevEdit == editKill && len(c.killRing) > 0 {
+		last := len(c.killRing) - 1
+		if end <= c.cursor {
+			// Killing backwards, so the text came before what was already killed.
+			c.killRing[last] = text + c.killRing[last]
+		} else {
+			c.killRing[last] += text
+		}
+	} else {
+		c.killRing = append(c.killRing, text)
+		if len(c.killRing) > killRingSize {
+			c.killRing = c.killRing[1:]
+		}
+	}
+
+	c.currline = c.currline[:start] + c.currline[end:]
+	c.cursor = start
+	c.lastEdit = editKill
+}
+
+// yank inserts the most recently killed text at the cursor.
+func (c *Console) yank() {
+	if len(c.killRing) == 0 {
+		return
+	}
+	c.yankIdx = len(c.killRing) - 1
+	c.insertYank(c.killRing[c.yankIdx])
+}
+
+// yankPop replaces text that was just yanked with the text killed before it,
+// cycling through the kill ring. It does nothing unless the previous command
+// was a yank.
+func (c *Console) yankPop() {
+	if c.prevEdit != editYank || len(c.killRing) == 0 {
+		return
+	}
+	c.currline = c.currline[:c.yankStart] + c.currline[c.cursor:]
+	c.cursor = c.yankStart
+	c.yankIdx = (c.yankIdx + len(c.killRing) - 1) % len(c.killRing)
+	c.insertYank(c.killRing[c.yankIdx])
+}
+
+// insertYank inserts yanked text at the cursor, remembering where it starts
+// so that it can be replaced by yankPop.
+func (c *Console) insertYank(text string) {
+	c.yankStart = c.cursor
+	c.Insert(text)
+	c.lastEdit = editYank
+}
+
+// clearScreen clears the console, leaving the current line at the top. Output
+// that is cleared away can still be scrolled back to.
+func (c *Console) clearScreen() {
+	c.clearedLines = len(c.lines)
+	c.snapToLive()
+}
+
+// lineUp moves the cursor up to the previous line of a command spanning
+// several lines, keeping it in the same column where possible. It returns
+// false if the cursor is already on the first line.
+func (c *Console) lineUp() bool {
+	end := strings.LastIndex(c.currline[:c.cursor], "\n")
+	if end < 0 {
+		return false
+	}
+	col := textWidth(c.currline[end+1 : c.cursor])
+	start := strings.LastIndex(c.currline[:end], "\n") + 1
+	c.cursor = columnOffset(c.currline, start, end, col)
+	return true
+}
+
+// lineDown moves the cursor down to the next line of a command spanning
+// several lines, keeping it in the same column where possible. It returns
+// false if the cursor is already on the last line.
+func (c *Console) lineDown() bool {
+	start := strings.Index(c.currline[c.cursor:], "\n")
+	if start < 0 {
+		return false
+	}
+	start += c.cursor + 1
+	lineStart := strings.LastIndex(c.currline[:c.cursor], "\n") + 1
+	col := textWidth(c.currline[lineStart:c.cursor])
+	end := strings.Index(c.currline[start:], "\n")
+	if end < 0 {
+		end = len(c.currline)
+	} else {
+		end += start
+	}
+	c.cursor = columnOffset(c.currline, start, end, col)
+	return true
+}
+
+// columnOffset returns the offset of the character in str[start:end] which is
+// shown in the given column, counting from start, or end if the text is too
+// short to reach it.
+func columnOffset(str string, start, end, col int) int {
+	width := 0
+	for i := start; i < end; {
+		next := nextBoundary(str, i)
+		width += clusterWidth(str[i:next])
+		if width > col {
+			return i
+		}
+		i = next
+	}
+	return end
+}
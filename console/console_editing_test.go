@@ -0,0 +1,92 @@
+package console
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestKillRing(t *testing.T) {
+	tests := []struct {
+		name   string
+		line   string
+		keys   string
+		want   string
+		cursor int
+	}{
+		{"kill and yank", "hello world", "M-b C-k C-a C-y", "worldhello ", 5},
+		{"kill to start", "hello world", "M-b C-u C-e C-y", "worldhello ", 11},
+		{"consecutive kills collect", "one two three", "M-b C-w C-w C-y", "one two three", 8},
+		{"word kills backwards", "one two three", "C-w C-w", "one ", 4},
+		{"kill word forwards", "one two three", "C-a M-d M-d", " three", 0},
+		{"yank pop", "one two", "C-w C-a C-k C-y C-y M-y", "one two", 7},
+		{"yank pop replaces the yank", "a b", "C-w C-a C-k C-y M-y", "b", 1},
+		{"yank pop cycles", "a b", "C-w C-a C-k C-y M-y M-y", "a ", 2},
+		{"yank pop needs a yank", "one two", "C-w C-y C-b M-y", "one two", 6},
+		{"transpose", "ab", "C-t", "ba", 2},
+	}
+	for _, tt := range tests {
+		c := NewConsole(0, 0, 80, 10)
+		c.SetLine(tt.line)
+		pressKeys(t, c, tt.keys)
+		if c.currline != tt.want || c.cursor != tt.cursor {
+			t.Errorf("%s: %q after %q = %q, cursor %d, want %q, cursor %d", tt.name,
+				tt.line, tt.keys, c.currline, c.cursor, tt.want, tt.cursor)
+		}
+	}
+}
+
+// blockExecuter is a MultiLineExecuter whose commands are complete once every
+// brace is closed. It records every command it executes.
+type blockExecuter struct {
+	con      *Console
+	executed []string
+}
+
+func (ex *blockExecuter) Execute(command string) (int, bool) {
+	ex.executed = append(ex.executed, command)
+	return 0, true
+}
+
+func (ex *blockExecuter) BoundConsole() *Console {
+	return ex.con
+}
+
+func (ex *blockExecuter) IsComplete(input string) bool {
+	return strings.Count(input, "{") <= strings.Count(input, "}")
+}
+
+func TestMultiLineInput(t *testing.T) {
+	c := NewConsole(0, 0, 80, 10)
+	ex := &blockExecuter{con: c}
+	c.SetExecuter(ex)
+
+	typeText(t, c, "f {")
+	pressKeys(t, c, "Enter")
+	typeText(t, c, "ab")
+	checkLine(t, c, "f {\nab", 6)
+	if len(ex.executed) != 0 {
+		t.Fatalf("incomplete command executed: %q", ex.executed)
+	}
+
+	// The up/down arrows move between the lines of the command, keeping the
+	// cursor's column, before moving through the history.
+	pressKeys(t, c, "Up")
+	checkLine(t, c, "f {\nab", 2)
+	pressKeys(t, c, "Down")
+	checkLine(t, c, "f {\nab", 6)
+
+	rows, row, col := c.wrapRows()
+	if len(rows) != 2 || rows[1].text != "... ab" || row != 1 || col != 6 {
+		t.Errorf("rows = %v, cursor at %d,%d, want a continuation row, 1,6",
+			rows, row, col)
+	}
+
+	pressKeys(t, c, "Enter")
+	typeText(t, c, "}")
+	pressKeys(t, c, "Enter")
+	if want := []string{"f {\nab\n}"}; !reflect.DeepEqual(ex.executed, want) {
+		t.Errorf("executed %q, want %q", ex.executed, want)
+	}
+	checkLine(t, c, "", 0)
+}
@@ -4,166 +4,253 @@ package console
 // a console component.
 
 import (
-	"sort"
+	"strings"
 
 	tb "github.com/nsf/termbox-go"
 
 	"github.com/mcprice30/ugcli"
 )
 
-const column_pad = 2
-
 // Run will be called to launch the console. It serves as the main activity
 // loop for the console, and implements the component interface, allowing
 // consoles to be embedded within an ugcli application.
-func (c *Console) Run(eq *ugcli.EventQueue) {
-
-	// Print the prompt for the first time.
-	c.Print(c.prompt)
+func (c *Console) Run(eq *ugcli.EventQueue) error {
 
 	// If we don't have an executer specified, simply use an echo executer.
 	if c.executer == nil {
 		c.executer = DefaultExecuter(c)
 	}
+	c.lock()
+	defer c.unlock()
+	c.events = eq
+	defer c.endRead()
+	defer c.cancelCompletion()
 
-	// Loop until finished.
+	// Loop until finished. The console is first drawn after its first event,
+	// which tells it where it belongs on the screen.
 	for c.running {
 
-		// In the event of an error, panic.
-		if err := tb.Flush(); err != nil {
-			panic(err)
+		// Get an event from the event queue, and apply it to the console. Other
+		// goroutines may use the console while waiting for it.
+		c.unlock()
+		event := eq.PollEvent()
+		c.lock()
+		c.handleEvent(event)
+		c.checkCompletion()
+
+		// Print anything that hooks, such as actions, printed while handling
+		// the event, so that it is drawn along with the event.
+		c.printQueued()
+
+		// Repaint the console. In the event of an error, stop the console and
+		// report it.
+		if err := c.draw(); err != nil {
+			c.running = false
+			return err
 		}
+	}
 
-		// Get an event from the event queue.
-		event := eq.PollEvent()
+	return nil
+}
 
-		// Delegate to the appropriate helper.
-		if event.Type == tb.EventKey {
-			switch event.Key {
-			case 0:
-				c.insertChar(event.Ch)
-			case tb.KeySpace:
-				c.insertChar(' ')
-			case tb.KeyEnter:
-				for i := c.getCursorLoc(); i < len(c.currline); i++ {
-					c.moveCursorRight()
-				}
-				c.executeLine()
-				c.promptY = c.cursorY
-			case tb.KeyCtrlC:
-				c.running = false
-			case tb.KeyBackspace, tb.KeyBackspace2:
-				c.backspace()
-			case tb.KeyArrowUp:
-				c.doArrowUp()
-			case tb.KeyArrowDown:
-				c.doArrowDown()
-			case tb.KeyTab:
-				c.doTabCompletion()
-			case tb.KeyArrowRight:
-				c.moveCursorRight()
-			case tb.KeyArrowLeft:
-				c.moveCursorLeft()
-			}
+// handleEvent delegates an event to the appropriate helper.
+func (c *Console) handleEvent(event ugcli.Event) {
+	switch event.Type {
+	case ugcli.EventFocus:
+		c.focused = true
+	case ugcli.EventBlur:
+		c.focused = false
+	case tb.EventResize:
+		c.doResize(event.Width, event.Height)
+	case ugcli.EventLayout:
+		c.resize(event.Rect.Top, event.Rect.Left, event.Rect.Width,
+			event.Rect.Height)
+	case tb.EventMouse:
+		switch event.Key {
+		case tb.MouseWheelUp:
+			c.scrollBy(wheelRows)
+		case tb.MouseWheelDown:
+			c.scrollBy(-wheelRows)
 		}
+	case tb.EventKey:
+		c.handleKey(ugcli.ChordOf(event.Event))
 	}
 }
 
-// executeLine will execute the current line, go to a new line, and print a
-// prompt for the next line.
+// doResize handles the terminal being resized to the given size. Consoles that
+// fill the terminal are resized to keep doing so.
+func (c *Console) doResize(width, height int) {
+	w, h := c.width, c.height
+	if c.fillWidth {
+		w = width - c.left
+	}
+	if c.fillHeight {
+		h = height - c.top
+	}
+	c.resize(c.top, c.left, w, h)
+}
+
+// executeLine will execute the current line, then start a new line for the
+// next command, which will follow the prompt. If a reader is waiting for a
+// line, it is given the current line instead.
 func (c *Console) executeLine() {
-	c.Println("")
-	if c.executer != nil {
-		_, c.running = c.executer.Execute(c.currline)
+	if c.read.pending {
+		c.readLine()
+		return
 	}
-	c.Print(c.prompt)
-	if len(c.currline) > 0 {
-		c.lineBuffer[c.bufferIdx%bufferSize] = c.currline
-		c.bufferIdx++
+	if ml, ok := c.executer.(MultiLineExecuter); ok && !ml.IsComplete(c.currline) {
+		c.insertChar('\n')
+		return
 	}
-	c.currline = ""
+
+	line := c.currline
+	c.commitLine()
+	c.SetLine("")
+	c.viReset()
+	c.addHistory(line)
 	c.diff = 0
 	c.oldLineCopy = ""
+
+	if c.executer != nil {
+		// The executer runs without holding the console, so that the command
+		// can write to it and read from it from other goroutines.
+		c.executing = true
+		c.unlock()
+		_, keepRunning := c.executer.Execute(line)
+		c.lock()
+		c.executing = false
+		if !keepRunning {
+			c.running = false
+		}
+	}
 }
 
 // doArrowDown will set the current line to a more recently executed command,
 // or what the user was typing before pressing the up arrow, if applicable.
+// Within a command spanning several lines, it first moves the cursor down
+// through them.
 func (c *Console) doArrowDown() {
-	if c.diff < -1 {
-		c.diff++
-		c.clearLine()
-		c.currline = c.lineBuffer[(c.bufferIdx+c.diff)%bufferSize]
-		c.Print(c.currline)
-	} else if c.diff == -1 {
-		c.diff++
-		c.clearLine()
-		c.currline = c.oldLineCopy
-		c.Print(c.currline)
+	if c.lineDown() {
+		return
+	}
+
+	n := c.history.Len()
+	for i := n + c.diff + 1; i < n; i++ {
+		if entry := c.history.Entry(i); c.historyMatches(entry) {
+			c.diff = i - n
+			c.SetLine(entry)
+			return
+		}
+	}
+	if c.diff < 0 {
+		c.diff = 0
+		c.SetLine(c.oldLineCopy)
 	}
 }
 
 // doArrowUp will set the current line to a less recently executed command.
+// Within a command spanning several lines, it first moves the cursor up
+// through them.
 func (c *Console) doArrowUp() {
-	if bufferSize+c.diff > 0 && c.bufferIdx+c.diff > 0 {
-		if c.diff == 0 {
-			c.oldLineCopy = c.currline
+	if c.lineUp() {
+		return
+	}
+
+	n := c.history.Len()
+	if c.diff == 0 {
+		c.oldLineCopy = c.currline
+	}
+	for i := n + c.diff - 1; i >= 0; i-- {
+		if entry := c.history.Entry(i); c.historyMatches(entry) {
+			c.diff = i - n
+			c.SetLine(entry)
+			return
 		}
-		c.diff--
-		c.clearLine()
-		c.currline = c.lineBuffer[(c.bufferIdx+c.diff)%bufferSize]
-		c.Print(c.currline)
 	}
 }
 
+// historyMatches indicates whether the arrow keys should stop at the given
+// command. With prefix search, they only stop at commands which start with
+// what the user had typed, skipping any that are the same as the line shown.
+func (c *Console) historyMatches(entry string) bool {
+	if !c.prefixSearch {
+		return true
+	}
+	return strings.HasPrefix(entry, c.oldLineCopy) && entry != c.currline
+}
+
 // doTabCompletion will ask the user-defined completer for recommendations
-// for the current line, before displaying them, if applicable.
+// for the current line, before displaying them in the completion menu, if
+// there are several. Completers which complete a single argument only replace
+// the argument being completed.
 func (c *Console) doTabCompletion() {
-	if c.completer != nil {
-		prefix, options := c.completer.Complete(c.currline)
-
-		if len(options) > 1 {
-			c.clearLine()
-			c.currline = prefix
-			c.Print(c.currline)
-			c.Println("")
-			c.printOptions(options)
-			c.promptY = c.cursorY
-			c.Print(c.prompt)
-			c.Print(c.currline)
-		} else if len(options) == 1 {
-			c.clearLine()
-			c.currline = prefix
-			c.Print(c.currline)
-		}
+	switch comp := c.completer.(type) {
+	case nil:
+	case ContextCompleter:
+		c.startCompletion(comp)
+	case SuggestionCompleter:
+		req, start, quote := c.completionRequest()
+		prefix, suggestions := comp.Suggest(req)
+		c.applyCompletion(prefix, suggestions, start, quote)
+	case ArgCompleter:
+		req, start, quote := c.completionRequest()
+		prefix, options := comp.CompleteArg(req)
+		c.applyCompletion(prefix, sortedSuggestions(options), start, quote)
+	default:
+		prefix, options := comp.Complete(c.currline)
+		c.applyCompletion(prefix, sortedSuggestions(options), -1, 0)
 	}
 }
 
-// printOptions is a utility function that will print a variety of strings
-// in columns.
-func (c *Console) printOptions(options []string) {
-	sort.Strings(options)
-	maxLen := 0
-	for _, option := range options {
-		if l := len(option); maxLen < l {
-			maxLen = l
-		}
+// applyCompletion places the result of completion into the current line,
+// replacing the argument starting at the given offset, or the whole line if
+// start is negative. If there are several suggestions, they are shown in the
+// completion menu.
+func (c *Console) applyCompletion(prefix string, suggestions []Suggestion, start int, quote byte) {
+	if len(suggestions) == 0 {
+		return
+	} else if start < 0 {
+		c.SetLine(prefix)
+	} else {
+		c.replaceArg(start, quote, prefix)
 	}
-	numColumns := (c.width + column_pad) / (maxLen + column_pad)
-	if numColumns == 0 {
-		numColumns++
+	if len(suggestions) > 1 {
+		c.openMenu(suggestions, start, quote)
 	}
-	printed := 0
-	for _, option := range options {
-		c.Print(option)
-		for i := len(option); i < maxLen+column_pad; i++ {
-			c.Print(" ")
-		}
-		printed++
-		if printed%numColumns == 0 {
-			c.Println("")
+}
+
+// replaceArg replaces the argument from the given offset up to the cursor
+// with text, quoted with the given quote, leaving the cursor after it.
+func (c *Console) replaceArg(start int, quote byte, text string) {
+	text = quoteWord(text, quote)
+	c.currline = c.currline[:start] + text + c.currline[c.cursor:]
+	c.cursor = start + len(text)
+}
+
+// completionRequest splits the current line into arguments, returning the
+// request to complete the argument the cursor is in, along with the offset
+// into the line that the argument starts at, and the quote left open in it
+// before the cursor, if any.
+func (c *Console) completionRequest() (req CompletionRequest, start int, quote byte) {
+	req = CompletionRequest{
+		Line:   c.currline,
+		Cursor: c.cursor,
+		Args:   []string{},
+	}
+	start = c.cursor
+	for _, token := range Tokenize(c.currline) {
+		if token.Start > c.cursor {
+			break
+		} else if token.End < c.cursor {
+			req.Args = append(req.Args, token.Text)
+		} else {
+			start = token.Start
+			break
 		}
 	}
-	if printed%numColumns != 0 {
-		c.Println("")
+
+	if typed := Tokenize(c.currline[start:c.cursor]); len(typed) > 0 {
+		req.Word, quote = typed[0].Text, typed[0].Quote
 	}
+	return req, start, quote
 }
@@ -0,0 +1,57 @@
+package console
+
+// console_highlight.go contains syntax highlighting of the current line, so
+// that parts of a command can be colored as the user types it.
+
+import (
+	tb "github.com/nsf/termbox-go"
+)
+
+// HighlightSpan formats part of the current line.
+type HighlightSpan struct {
+
+	// The offsets, in bytes, into the line that the formatting starts and ends
+	// at.
+	Start, End int
+
+	// The foreground of the text, along with any attributes such as
+	// tb.AttrBold or tb.AttrUnderline.
+	Fg tb.Attribute
+
+	// The background of the text.
+	Bg tb.Attribute
+}
+
+// Highlighter decides how the current line is formatted as the user types it.
+type Highlighter interface {
+
+	// Highlight returns the formatting of the given line. Text outside of any
+	// span is drawn in the default colors, and where spans overlap, the last
+	// one wins.
+	Highlight(line string) []HighlightSpan
+}
+
+// SetHighlighter attaches a user-defined highlighter to the console, which
+// formats the current line again every time it is edited. A nil Highlighter
+// draws the line in the default colors.
+func (c *Console) SetHighlighter(h Highlighter) {
+	c.highlighter = h
+	c.highlighted = ""
+	c.highlights = nil
+	if h != nil {
+		c.highlight()
+	}
+}
+
+// highlight asks the highlighter to format the current line, if it has
+// changed since it was last formatted.
+func (c *Console) highlight() {
+	if c.highlighter == nil || (c.highlights != nil && c.highlighted == c.currline) {
+		return
+	}
+	c.highlighted = c.currline
+	c.highlights = []span{}
+	for _, s := range c.highlighter.Highlight(c.currline) {
+		c.highlights = append(c.highlights, span{s.Start, s.End, s.Fg, s.Bg})
+	}
+}
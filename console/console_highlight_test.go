@@ -0,0 +1,63 @@
+package console
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	tb "github.com/nsf/termbox-go"
+)
+
+// commandHighlighter colors the first word of a line, counting how many lines
+// it has highlighted.
+type commandHighlighter struct {
+	calls int
+}
+
+func (h *commandHighlighter) Highlight(line string) []HighlightSpan {
+	h.calls++
+	end := strings.Index(line, " ")
+	if end < 0 {
+		end = len(line)
+	}
+	return []HighlightSpan{{Start: 0, End: end, Fg: tb.ColorGreen | tb.AttrBold}}
+}
+
+func TestHighlighter(t *testing.T) {
+	h := &commandHighlighter{}
+	c := NewConsole(0, 0, 80, 10)
+	c.SetExecuter(&blockExecuter{con: c})
+	c.SetHighlighter(h)
+	typeText(t, c, "ls -l")
+
+	c.highlight()
+	for offset, want := range []tb.Attribute{
+		tb.ColorGreen | tb.AttrBold, tb.ColorGreen | tb.AttrBold,
+		tb.ColorDefault, tb.ColorDefault, tb.ColorDefault,
+	} {
+		if fg, bg := c.lineFmt(offset); fg != want || bg != tb.ColorDefault {
+			t.Errorf("format at %d = %v, %v, want %v, default", offset, fg, bg, want)
+		}
+	}
+
+	// The line is only highlighted again once it changes.
+	calls := h.calls
+	c.highlight()
+	c.highlight()
+	if h.calls != calls {
+		t.Errorf("unchanged line highlighted %d more times", h.calls-calls)
+	}
+
+	// The line keeps its formatting once it has been executed.
+	pressKeys(t, c, "Enter")
+	want := []span{{len(defaultPrompt), len(defaultPrompt) + 2, tb.ColorGreen | tb.AttrBold, tb.ColorDefault}}
+	if n := len(c.lineSpans); n == 0 || !reflect.DeepEqual(c.lineSpans[n-1], want) {
+		t.Errorf("executed line's spans = %v, want %v", c.lineSpans, want)
+	}
+
+	c.SetHighlighter(nil)
+	typeText(t, c, "ls")
+	if fg, _ := c.lineFmt(0); fg != tb.ColorDefault {
+		t.Errorf("format without a highlighter = %v, want default", fg)
+	}
+}
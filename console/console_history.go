@@ -0,0 +1,342 @@
+package console
+
+// console_history.go contains the console's history of executed commands,
+// along with its default implementation, which can optionally be saved to a
+// file so that it lasts across sessions.
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"strings"
+)
+
+// History stores the commands executed in a console, so that the user can
+// move back through them and search them. Commands are indexed from 0, the
+// oldest, to Len()-1, the most recent.
+type History interface {
+
+	// Add records a command that was just executed.
+	Add(line string)
+
+	// Len returns how many commands are in the history.
+	Len() int
+
+	// Entry returns the command at the given index.
+	Entry(i int) string
+
+	// Search looks for the nearest command containing query, starting from
+	// the command at index start and moving to older commands if reverse is
+	// set, or newer ones otherwise. It returns the command's index, along with
+	// the offset into it at which query was found, or an index of -1 if no
+	// command matches.
+	Search(query string, start int, reverse bool) (idx, pos int)
+}
+
+// HistoryFlags choose which commands are left out of a console's history.
+type HistoryFlags int
+
+const (
+	// HistoryIgnoreDups leaves out commands which are the same as the command
+	// executed just before them.
+	HistoryIgnoreDups HistoryFlags = 1 << iota
+
+	// HistoryIgnoreSpace leaves out commands which start with a space, so that
+	// users can keep a command out of the history.
+	HistoryIgnoreSpace
+)
+
+// errNoHistoryFile is returned when setting a history file for a console
+// whose history can't be saved to one.
+var errNoHistoryFile = errors.New("console: history doesn't support files")
+
+// SetHistory sets where the console stores the commands executed in it. By
+// default, a RingHistory holding up to 100 commands is used.
+func (c *Console) SetHistory(h History) {
+	c.history = h
+	c.diff = 0
+	c.suggestionSet = false
+}
+
+// History returns where the console stores the commands executed in it.
+func (c *Console) History() History {
+	return c.history
+}
+
+// SetHistorySize sets the maximum number of previously executed commands that
+// the console remembers, and that are kept in its history file, if any. It
+// only applies if the console's history is a RingHistory.
+func (c *Console) SetHistorySize(size int) {
+	if h, ok := c.history.(*RingHistory); ok {
+		h.SetSize(size)
+		c.diff = 0
+	}
+}
+
+// SetHistoryFlags chooses which commands are left out of the console's
+// history. By default, every command is kept.
+func (c *Console) SetHistoryFlags(flags HistoryFlags) {
+	c.historyFlags = flags
+}
+
+// SetHistoryPrefixSearch sets whether moving through the history with the
+// up/down arrows only visits commands which start with what the user had typed
+// before they started moving, rather than every command. Moving down past the
+// newest such command restores what was typed.
+func (c *Console) SetHistoryPrefixSearch(enabled bool) {
+	c.prefixSearch = enabled
+}
+
+// SetHistoryFile loads the console's history from the file at the given path,
+// after which every command executed is appended to the file, as described
+// for RingHistory.SetFile. It returns an error if the console's history isn't
+// a RingHistory. Failures to save commands are reported by RingHistory.Err.
+func (c *Console) SetHistoryFile(path string) error {
+	h, ok := c.history.(*RingHistory)
+	if !ok {
+		return errNoHistoryFile
+	}
+	c.diff = 0
+	return h.SetFile(path)
+}
+
+// addHistory records an executed command in the history, unless the
+// console's history flags leave it out.
+func (c *Console) addHistory(line string) {
+	if len(line) == 0 {
+		return
+	}
+	if c.historyFlags&HistoryIgnoreSpace != 0 && line[0] == ' ' {
+		return
+	}
+	n := c.history.Len()
+	if c.historyFlags&HistoryIgnoreDups != 0 && n > 0 &&
+		c.history.Entry(n-1) == line {
+		return
+	}
+	c.history.Add(line)
+}
+
+// RingHistory is the default History. It remembers a fixed number of the most
+// recent commands, optionally saving them to a file.
+type RingHistory struct {
+
+	// Holds the commands, in a ring whose length is the maximum number of
+	// commands to remember.
+	entries []string
+
+	// What index of the ring the next command would be written into, counting
+	// every command ever added.
+	next int
+
+	// The file that commands are saved to, or "" if they are only kept in
+	// memory.
+	file string
+
+	// The error from the most recent attempt to save a command to the file, if
+	// it failed.
+	err error
+}
+
+// NewRingHistory returns a history which remembers up to size commands.
+func NewRingHistory(size int) *RingHistory {
+	if size < 1 {
+		size = 1
+	}
+	return &RingHistory{
+		entries: make([]string, size),
+	}
+}
+
+// Add records a command, saving it to the history file, if any. Errors in
+// saving it don't interrupt the user, but are reported by Err.
+func (h *RingHistory) Add(line string) {
+	h.push(line)
+	if h.file != "" {
+		h.err = h.save(line)
+	}
+}
+
+// Err returns the error from the most recent attempt to save a command to the
+// history file, or nil if it succeeded. The command is still kept in memory.
+func (h *RingHistory) Err() error {
+	return h.err
+}
+
+// Len returns how many commands are in the history.
+func (h *RingHistory) Len() int {
+	if h.next < len(h.entries) {
+		return h.next
+	}
+	return len(h.entries)
+}
+
+// Entry returns the command at the given index, where 0 is the oldest.
+func (h *RingHistory) Entry(i int) string {
+	return h.entries[(h.next-h.Len()+i)%len(h.entries)]
+}
+
+// Search looks for the nearest command containing query, as described for
+// History. In a reverse search, the last place query is found within a
+// command is returned, or the first place otherwise.
+func (h *RingHistory) Search(query string, start int, reverse bool) (idx, pos int) {
+	step := 1
+	if reverse {
+		step = -1
+	}
+	for i := start; i >= 0 && i < h.Len(); i += step {
+		entry := h.Entry(i)
+		pos := strings.Index(entry, query)
+		if reverse {
+			pos = strings.LastIndex(entry, query)
+		}
+		if pos >= 0 {
+			return i, pos
+		}
+	}
+	return -1, 0
+}
+
+// SetSize sets the maximum number of commands that the history remembers,
+// and that are kept in its file, if any. The most recent commands are kept.
+func (h *RingHistory) SetSize(size int) {
+	if size < 1 {
+		size = 1
+	}
+	entries := h.all()
+	if len(entries) > size {
+		entries = entries[len(entries)-size:]
+	}
+	h.entries = make([]string, size)
+	h.next = 0
+	for _, entry := range entries {
+		h.push(entry)
+	}
+}
+
+// SetFile loads the history from the file at the given path, after which every
+// command added is appended to the file. The file is created if it doesn't
+// exist, and is locked while being read or written, so that several consoles
+// may share it. An empty path keeps history only in memory. If the file can't
+// be loaded, the history is left as it was, along with any file it was saved
+// to before.
+func (h *RingHistory) SetFile(path string) error {
+	if path == "" {
+		h.file, h.err = "", nil
+		return nil
+	}
+
+	f, err := os.OpenFile(path, os.O_RDONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := lockFile(f, false); err != nil {
+		return err
+	}
+	defer unlockFile(f)
+
+	entries, err := readHistory(f)
+	if err != nil {
+		return err
+	}
+	if len(entries) > len(h.entries) {
+		entries = entries[len(entries)-len(h.entries):]
+	}
+	h.next = 0
+	for _, entry := range entries {
+		h.push(entry)
+	}
+	h.file, h.err = path, nil
+	return nil
+}
+
+// push writes a command into the next slot of the ring.
+func (h *RingHistory) push(line string) {
+	h.entries[h.next%len(h.entries)] = line
+	h.next++
+}
+
+// all returns the commands in the history, oldest first.
+func (h *RingHistory) all() []string {
+	entries := make([]string, 0, h.Len())
+	for i := 0; i < h.Len(); i++ {
+		entries = append(entries, h.Entry(i))
+	}
+	return entries
+}
+
+// save appends a command to the history file. If the file has grown past the
+// history size, such as from other consoles sharing it, it is rewritten to
+// hold only the most recent commands.
+func (h *RingHistory) save(line string) error {
+	f, err := os.OpenFile(h.file, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := lockFile(f, true); err != nil {
+		return err
+	}
+	defer unlockFile(f)
+
+	entries, err := readHistory(f)
+	if err != nil {
+		return err
+	}
+	entries = append(entries, line)
+	if len(entries) <= len(h.entries) {
+		_, err = f.WriteString(encodeHistory(line) + "\n")
+		return err
+	}
+
+	entries = entries[len(entries)-len(h.entries):]
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	for _, entry := range entries {
+		w.WriteString(encodeHistory(entry) + "\n")
+	}
+	return w.Flush()
+}
+
+// readHistory reads every command from a history file, leaving the file's
+// offset at its end.
+func readHistory(f *os.File) ([]string, error) {
+	entries := []string{}
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			entries = append(entries, decodeHistory(line))
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// encodeHistory escapes backslashes and newlines in a command, so that it
+// can be stored on a single line of the history file.
+func encodeHistory(line string) string {
+	line = strings.Replace(line, `\`, `\\`, -1)
+	return strings.Replace(line, "\n", `\n`, -1)
+}
+
+// decodeHistory reverses encodeHistory.
+func decodeHistory(line string) string {
+	var b strings.Builder
+	for i := 0; i < len(line); i++ {
+		if line[i] == '\\' && i+1 < len(line) {
+			i++
+			if line[i] == 'n' {
+				b.WriteByte('\n')
+				continue
+			}
+		}
+		b.WriteByte(line[i])
+	}
+	return b.String()
+}
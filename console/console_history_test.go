@@ -0,0 +1,197 @@
+package console
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEncodeHistory(t *testing.T) {
+	tests := []struct {
+		line, encoded string
+	}{
+		{"", ""},
+		{"ls -l", "ls -l"},
+		{"a\nb", `a\nb`},
+		{`C:\dir`, `C:\\dir`},
+		{`\n`, `\\n`},
+		{"\\\n", `\\\n`},
+		{"end\\", `end\\`},
+	}
+	for _, tt := range tests {
+		encoded := encodeHistory(tt.line)
+		if encoded != tt.encoded {
+			t.Errorf("encodeHistory(%q) = %q, want %q", tt.line, encoded, tt.encoded)
+		}
+		if strings.Contains(encoded, "\n") {
+			t.Errorf("encodeHistory(%q) = %q contains a newline", tt.line, encoded)
+		}
+		if decoded := decodeHistory(encoded); decoded != tt.line {
+			t.Errorf("decodeHistory(%q) = %q, want %q", encoded, decoded, tt.line)
+		}
+	}
+}
+
+func TestRingHistoryFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "history")
+	h := NewRingHistory(3)
+	if err := h.SetFile(file); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"one", "two\nlines", "three", "four"} {
+		h.Add(line)
+	}
+
+	reloaded := NewRingHistory(3)
+	if err := reloaded.SetFile(file); err != nil {
+		t.Fatal(err)
+	}
+	got := []string{}
+	for i := 0; i < reloaded.Len(); i++ {
+		got = append(got, reloaded.Entry(i))
+	}
+	if want := []string{"two\nlines", "three", "four"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("reloaded history = %q, want %q", got, want)
+	}
+}
+
+func TestRingHistoryFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "history")
+	h := NewRingHistory(3)
+	if err := h.SetFile(file); err != nil {
+		t.Fatal(err)
+	}
+	h.Add("one")
+
+	// A file which can't be loaded leaves the history saving to the old one.
+	if err := h.SetFile(filepath.Join(dir, "missing", "history")); err == nil {
+		t.Error("SetFile of a missing directory succeeded")
+	}
+	h.Add("two")
+	if err := h.Err(); err != nil {
+		t.Errorf("Err = %v, want nil", err)
+	}
+	reloaded := NewRingHistory(3)
+	if err := reloaded.SetFile(file); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := entries(reloaded), []string{"one", "two"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("reloaded history = %q, want %q", got, want)
+	}
+
+	// Failing to save a command is reported, but the command is still kept.
+	if err := os.Remove(file); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(file, 0700); err != nil {
+		t.Fatal(err)
+	}
+	h.Add("three")
+	if h.Err() == nil {
+		t.Error("Err = nil after failing to save")
+	}
+	if got, want := entries(h), []string{"one", "two", "three"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("history = %q, want %q", got, want)
+	}
+}
+
+// entries returns the commands in a history, oldest first.
+func entries(h History) []string {
+	lines := []string{}
+	for i := 0; i < h.Len(); i++ {
+		lines = append(lines, h.Entry(i))
+	}
+	return lines
+}
+
+func TestHistoryFlags(t *testing.T) {
+	c := NewConsole(0, 0, 80, 10)
+	c.SetHistoryFlags(HistoryIgnoreDups | HistoryIgnoreSpace)
+	for _, line := range []string{"a", "a", " secret", "b", "a"} {
+		c.addHistory(line)
+	}
+	if got, want := entries(c.History()), []string{"a", "b", "a"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("history = %q, want %q", got, want)
+	}
+}
+
+func TestRingHistorySearch(t *testing.T) {
+	h := NewRingHistory(10)
+	for _, line := range []string{"git status", "ls", "git commit"} {
+		h.Add(line)
+	}
+	tests := []struct {
+		query    string
+		start    int
+		reverse  bool
+		idx, pos int
+	}{
+		{"git", 2, true, 2, 0},
+		{"git", 1, true, 0, 0},
+		{"status", 2, true, 0, 4},
+		{"git", 1, false, 2, 0},
+		{"nope", 2, true, -1, 0},
+	}
+	for _, tt := range tests {
+		idx, pos := h.Search(tt.query, tt.start, tt.reverse)
+		if idx != tt.idx || (idx >= 0 && pos != tt.pos) {
+			t.Errorf("Search(%q, %d, %v) = %d, %d, want %d, %d", tt.query, tt.start,
+				tt.reverse, idx, pos, tt.idx, tt.pos)
+		}
+	}
+}
+
+// sliceHistory is a History which keeps every command in a slice.
+type sliceHistory struct {
+	lines []string
+}
+
+func (h *sliceHistory) Add(line string)    { h.lines = append(h.lines, line) }
+func (h *sliceHistory) Len() int           { return len(h.lines) }
+func (h *sliceHistory) Entry(i int) string { return h.lines[i] }
+func (h *sliceHistory) Search(query string, start int, reverse bool) (int, int) {
+	return -1, 0
+}
+
+func TestCustomHistory(t *testing.T) {
+	h := &sliceHistory{lines: []string{"first", "second"}}
+	c := NewConsole(0, 0, 80, 10)
+	c.SetHistory(h)
+	pressKeys(t, c, "Up")
+	checkLine(t, c, "second", 6)
+	pressKeys(t, c, "Up")
+	checkLine(t, c, "first", 5)
+	pressKeys(t, c, "Down Down")
+	checkLine(t, c, "", 0)
+
+	c.addHistory("third")
+	if got, want := h.lines, []string{"first", "second", "third"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("history = %q, want %q", got, want)
+	}
+}
+
+func TestHistoryPrefixSearch(t *testing.T) {
+	c := NewConsole(0, 0, 80, 10)
+	c.SetHistoryPrefixSearch(true)
+	for _, line := range []string{"git status", "ls", "git commit", "git commit", "make"} {
+		c.History().Add(line)
+	}
+	typeText(t, c, "git")
+
+	// Commands which don't start with what was typed are skipped, as are
+	// repeats of the command already shown.
+	pressKeys(t, c, "Up")
+	checkLine(t, c, "git commit", 10)
+	pressKeys(t, c, "Up")
+	checkLine(t, c, "git status", 10)
+	pressKeys(t, c, "Up")
+	checkLine(t, c, "git status", 10)
+
+	pressKeys(t, c, "Down")
+	checkLine(t, c, "git commit", 10)
+	pressKeys(t, c, "Down")
+	checkLine(t, c, "git", 3)
+}
@@ -0,0 +1,266 @@
+package console
+
+// console_io.go contains adapters letting the console be used as an io.Writer
+// and io.Reader, such as for loggers or commands run by an executer, which
+// may write to the console and read from it from other goroutines.
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+// tabWidth is how many columns apart tab stops are in output.
+const tabWidth = 8
+
+// readState holds the state of a reader waiting for the user to enter a line.
+type readState struct {
+
+	// Serializes readers, so that each line entered goes to one of them.
+	mu sync.Mutex
+
+	// Indicates whether a reader is waiting for a line. While it is, the line
+	// entered is given to the reader, rather than being executed.
+	pending bool
+
+	// Receives the line once it has been entered. It is closed if the console
+	// stops first.
+	line chan string
+}
+
+// outputQueue holds output printed while the console is held. Hooks such as
+// actions and completers run while the console is held, so output they print
+// can't wait for it to be released, and is printed once they return instead.
+type outputQueue struct {
+
+	// Guards writes.
+	mu sync.Mutex
+
+	// Set, atomically, while the console is held.
+	held int32
+
+	// Output waiting to be printed, oldest first.
+	writes []queuedWrite
+}
+
+// queuedWrite is output waiting to be printed.
+type queuedWrite struct {
+
+	// text is the output, which may contain escape sequences.
+	text string
+
+	// style is the style the output is printed in, or nil for the console's
+	// own style, which escape sequences change from then on.
+	style *Style
+}
+
+// Writer returns an io.Writer which prints to the console, as Print does.
+// It is safe to use from any goroutine, even while a command is being
+// executed, such as for a log.Logger or the output of an exec.Cmd.
+//
+// Output is handled as a terminal would handle it: "\n" ends the line, "\r"
+// returns to the start of it, so that what follows overwrites it, backspace
+// moves back over a character, and "\t" moves on to the next tab stop. ANSI
+// escape sequences setting colors and attributes style the text that follows
+// them.
+func (c *Console) Writer() io.Writer {
+	return consoleWriter{c}
+}
+
+// consoleWriter is the io.Writer returned by Console.Writer.
+type consoleWriter struct {
+	// c is the console written to.
+	c *Console
+}
+
+// Write prints p to the console, then shows it. It returns any error from
+// drawing the console.
+func (w consoleWriter) Write(p []byte) (n int, err error) {
+	return len(p), w.c.print(string(p), nil)
+}
+
+// print prints a string in the given style, or the console's own style if it
+// is nil, then shows it, returning any error from drawing the console. If the
+// console is held, possibly by the caller, the string is queued instead, and
+// printed once the console is released.
+func (c *Console) print(str string, style *Style) error {
+	c.out.mu.Lock()
+	c.out.writes = append(c.out.writes, queuedWrite{str, style})
+	c.out.mu.Unlock()
+	if atomic.LoadInt32(&c.out.held) != 0 {
+		return nil
+	}
+
+	c.lock()
+	defer c.unlock()
+	if !c.printQueued() {
+		return nil
+	}
+	return c.show()
+}
+
+// printQueued prints any output queued while the console was held, returning
+// whether there was any. The console must be held.
+func (c *Console) printQueued() bool {
+	c.out.mu.Lock()
+	writes := c.out.writes
+	c.out.writes = nil
+	c.out.mu.Unlock()
+
+	for _, w := range writes {
+		if w.style == nil {
+			c.printStyled(w.text, &c.style)
+		} else {
+			style := *w.style
+			c.printStyled(w.text, &style)
+		}
+	}
+	return len(writes) > 0
+}
+
+// lock holds the console, so that output printed until it is released is
+// queued.
+func (c *Console) lock() {
+	c.mu.Lock()
+	atomic.StoreInt32(&c.out.held, 1)
+}
+
+// unlock releases the console, first printing any output that was queued
+// while it was held.
+func (c *Console) unlock() {
+	queued := c.printQueued()
+	atomic.StoreInt32(&c.out.held, 0)
+
+	// Output may have been queued by another goroutine just before the console
+	// was marked as released, and would otherwise wait for the next time it is
+	// held.
+	if c.printQueued() || queued {
+		// Should drawing fail, the console's loop reports the error when it
+		// next draws.
+		c.show()
+	}
+	c.mu.Unlock()
+}
+
+// show shows output that was just printed. While the console's loop is
+// waiting for the executer, it won't draw the output until the command
+// finishes, so the output is drawn straight away. Otherwise, the loop is woken
+// to draw it.
+func (c *Console) show() error {
+	if c.executing {
+		return c.draw()
+	} else if c.events != nil {
+		c.events.Wake()
+	}
+	return nil
+}
+
+// ReadLine waits for the user to enter a line in the console, returning it
+// without its trailing newline. The line is entered just as a command would
+// be, but is given to the caller instead of being executed, and the prompt
+// isn't shown, so it follows whatever was last printed. It returns io.EOF if
+// the console stops first.
+//
+// ReadLine is safe to use from any goroutine, including from an executer
+// while it executes a command, but not from within a hook the console calls
+// while it is held, such as an Action or a Completer, since the console can't
+// take the line until the hook returns.
+func (c *Console) ReadLine() (string, error) {
+	c.read.mu.Lock()
+	defer c.read.mu.Unlock()
+
+	c.lock()
+	defer c.unlock()
+	if !c.running {
+		return "", io.EOF
+	}
+	line := make(chan string, 1)
+	c.read.pending, c.read.line = true, line
+
+	// While the console's loop is waiting for the executer, nothing else
+	// handles keys, so handle them here until the line has been entered.
+	// Should the executer return first, the loop takes over.
+	for c.executing && c.read.pending && c.running {
+		c.printQueued()
+		c.draw()
+		c.unlock()
+		event := c.events.PollEvent()
+		c.lock()
+		c.handleEvent(event)
+		c.checkCompletion()
+	}
+	if !c.running {
+		c.endRead()
+	}
+	c.show()
+
+	// Otherwise, the console's loop hands over the line once it has been
+	// entered.
+	c.unlock()
+	l, ok := <-line
+	c.lock()
+	if !ok {
+		return "", io.EOF
+	}
+	return l, nil
+}
+
+// Reader returns an io.Reader which reads lines entered in the console, as
+// ReadLine does, each followed by a newline. It returns io.EOF once the
+// console stops.
+//
+// A Read waits until the user enters a line, and can't be interrupted, so the
+// reader shouldn't be given to anything which waits for its reads to finish,
+// such as the Stdin of an exec.Cmd, whose Wait would hang until another line
+// is entered. Copy from the reader to the command's StdinPipe instead, so
+// that the command can finish without waiting for the copy.
+func (c *Console) Reader() io.Reader {
+	return &consoleReader{c: c}
+}
+
+// consoleReader is the io.Reader returned by Console.Reader.
+type consoleReader struct {
+
+	// c is the console read from.
+	c *Console
+
+	// buf holds the rest of the line last entered, which hasn't been read yet.
+	buf []byte
+}
+
+// Read reads from the line last entered in the console, waiting for the user
+// to enter another once it has all been read.
+func (r *consoleReader) Read(p []byte) (n int, err error) {
+	if len(r.buf) == 0 {
+		line, err := r.c.ReadLine()
+		if err != nil {
+			return 0, err
+		}
+		r.buf = []byte(line + "\n")
+	}
+	n = copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
+
+// readLine gives the current line to the waiting reader, leaving it in the
+// scrollback, as it was shown.
+func (c *Console) readLine() {
+	line := c.currline
+	c.commitLine()
+	c.SetLine("")
+	c.viReset()
+	c.diff = 0
+	c.oldLineCopy = ""
+
+	c.read.pending = false
+	c.read.line <- line
+}
+
+// endRead stops any reader waiting for a line, which then gets io.EOF.
+func (c *Console) endRead() {
+	if c.read.pending {
+		c.read.pending = false
+		close(c.read.line)
+	}
+}
@@ -0,0 +1,167 @@
+package console
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	tb "github.com/nsf/termbox-go"
+)
+
+// waitReading waits for a reader to be waiting for the console's line.
+func waitReading(t *testing.T, c *Console) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		c.mu.Lock()
+		pending := c.read.pending
+		c.mu.Unlock()
+		if pending {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("no reader waiting")
+}
+
+func TestWriter(t *testing.T) {
+	c := NewConsole(0, 0, 80, 10)
+	w := c.Writer()
+	if n, err := fmt.Fprint(w, "\x1b[31mred\x1b[0m\t|"); n != 14 || err != nil {
+		t.Errorf("Write = %d, %v, want 14, nil", n, err)
+	}
+	fmt.Fprintln(w, "done")
+	if want := "red     |done"; c.lines[0] != want {
+		t.Errorf("line = %q, want %q", c.lines[0], want)
+	}
+}
+
+func TestWriterConcurrent(t *testing.T) {
+	c := NewConsole(0, 0, 80, 10)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			logger := log.New(c.Writer(), "", 0)
+			for j := 0; j < 50; j++ {
+				if i%2 == 0 {
+					logger.Printf("%d.%d", i, j)
+				} else {
+					c.Println(fmt.Sprintf("%d.%d", i, j))
+				}
+			}
+		}(i)
+	}
+	typeText(t, c, "abc")
+	wg.Wait()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if n := len(c.lines); n != 200 {
+		t.Errorf("printed %d lines, want 200", n)
+	}
+	checkLine(t, c, "abc", 3)
+}
+
+func TestReadLine(t *testing.T) {
+	c := NewConsole(0, 0, 80, 10)
+	ex := &blockExecuter{con: c}
+	c.SetExecuter(ex)
+	lines := make(chan string)
+	go func() {
+		r := c.Reader()
+		buf := make([]byte, 4)
+		for {
+			n, err := r.Read(buf)
+			if err != nil {
+				close(lines)
+				return
+			}
+			lines <- string(buf[:n])
+		}
+	}()
+
+	// The line entered goes to the reader, rather than being executed, and
+	// reads continue through it a piece at a time.
+	waitReading(t, c)
+	if prompt := c.promptText(); prompt != "" {
+		t.Errorf("prompt while reading = %q, want none", prompt)
+	}
+	typeText(t, c, "hello")
+	pressKeys(t, c, "Enter")
+	for _, want := range []string{"hell", "o\n"} {
+		if got := <-lines; got != want {
+			t.Errorf("read %q, want %q", got, want)
+		}
+	}
+	if len(ex.executed) != 0 {
+		t.Errorf("read line was executed: %q", ex.executed)
+	}
+
+	// Once the reader has its line, lines are executed again.
+	waitReading(t, c)
+	c.mu.Lock()
+	c.endRead()
+	c.mu.Unlock()
+	if _, ok := <-lines; ok {
+		t.Error("reader didn't stop once the console stopped")
+	}
+	typeText(t, c, "ls")
+	pressKeys(t, c, "Enter")
+	if len(ex.executed) != 1 {
+		t.Errorf("executed %q, want one command", ex.executed)
+	}
+
+	c.running = false
+	if _, err := c.ReadLine(); err != io.EOF {
+		t.Errorf("ReadLine once stopped = %v, want io.EOF", err)
+	}
+}
+
+// printingCompleter is a Completer which prints what it is asked to complete.
+type printingCompleter struct {
+	c *Console
+}
+
+func (pc printingCompleter) Complete(input string) (string, []string) {
+	fmt.Fprintf(pc.c.Writer(), "completing %q\n", input)
+	return input, []string{}
+}
+
+func TestPrintFromHooks(t *testing.T) {
+	c := NewConsole(0, 0, 80, 10)
+	c.SetCompleter(printingCompleter{c})
+	c.Keymap().RegisterAction("greet", func(c *Console) {
+		c.Println("hello")
+		c.PrintlnStyled("there", Style{Fg: tb.ColorRed})
+	})
+	if err := c.Keymap().Bind("C-o", "greet"); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan bool)
+	go func() {
+		typeText(t, c, "ls")
+		pressKeys(t, c, "C-o Tab")
+		done <- true
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("printing from a hook deadlocked")
+	}
+
+	want := []string{"hello", "there", `completing "ls"`}
+	if !reflect.DeepEqual(c.lines, want) {
+		t.Errorf("lines = %q, want %q", c.lines, want)
+	}
+	if fg, _ := spanFmt(c.lineSpans[1], 0); fg != tb.ColorRed {
+		t.Errorf("foreground of styled output = %v, want %v", fg, tb.ColorRed)
+	}
+	checkLine(t, c, "ls", 2)
+}
@@ -0,0 +1,19 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package console
+
+// console_lock_other.go stands in for file locking on platforms without
+// flock, where consoles sharing a history file may interleave their writes.
+
+import "os"
+
+// lockFile does nothing on this platform.
+func lockFile(f *os.File, exclusive bool) error {
+	return nil
+}
+
+// unlockFile does nothing on this platform.
+func unlockFile(f *os.File) error {
+	return nil
+}
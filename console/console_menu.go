@@ -0,0 +1,265 @@
+package console
+
+// console_menu.go contains the completion menu, which lists the suggestions
+// for the argument being completed just below the cursor, letting the user
+// pick one without printing anything into the console's output.
+
+import (
+	tb "github.com/nsf/termbox-go"
+)
+
+// menuRows indicates the maximum number of suggestions shown at once in the
+// completion menu. Longer menus scroll to keep the selected suggestion shown.
+const menuRows = 8
+
+// menuDescPad indicates the number of cells between a suggestion and its
+// description in the completion menu.
+const menuDescPad = 2
+
+// Formats used in drawing the completion menu. The characters of each
+// suggestion that matched what the user typed are also drawn with
+// suggestionMatchFmt.
+const (
+	suggestionMatchFmt = tb.AttrBold | tb.AttrUnderline
+	menuFmt            = tb.ColorDefault
+	menuSelectedFmt    = tb.ColorDefault | tb.AttrReverse
+	menuDescFmt        = tb.ColorDefault | tb.AttrDim
+)
+
+// menuState holds the state of the completion menu.
+type menuState struct {
+
+	// Indicates whether the menu is shown.
+	active bool
+
+	// The suggestions listed in the menu.
+	suggestions []Suggestion
+
+	// Which suggestion is selected, or -1 if none is.
+	selected int
+
+	// Which suggestion is listed first, if the menu has been scrolled.
+	top int
+
+	// The offset into the current line of the argument being completed,
+	// along with the quote it was typed in, if any.
+	start int
+	quote byte
+
+	// Indicates whether the suggestions are for the whole line, rather than
+	// a single argument, and so are placed into the line without quoting.
+	wholeLine bool
+
+	// What the current line was when the menu was opened, along with the
+	// cursor, so that they can be restored if the menu is cancelled.
+	origLine   string
+	origCursor int
+}
+
+// openMenu shows the completion menu, listing the given suggestions for the
+// argument starting at the given offset into the current line, or for the
+// whole line if start is negative.
+func (c *Console) openMenu(suggestions []Suggestion, start int, quote byte) {
+	c.menu = menuState{
+		active:      true,
+		suggestions: suggestions,
+		selected:    -1,
+		start:       start,
+		quote:       quote,
+		wholeLine:   start < 0,
+		origLine:    c.currline,
+		origCursor:  c.cursor,
+	}
+}
+
+// menuSelect selects the suggestion step places after the selected one,
+// wrapping around at either end of the menu, and places it into the line.
+func (c *Console) menuSelect(step int) {
+	n := len(c.menu.suggestions)
+	if c.menu.selected < 0 && step < 0 {
+		c.menu.selected = n + step
+	} else {
+		c.menu.selected = (c.menu.selected + step) % n
+	}
+	if c.menu.selected < 0 {
+		c.menu.selected += n
+	}
+
+	text := c.menu.suggestions[c.menu.selected].Text
+	if c.menu.wholeLine {
+		c.SetLine(text)
+		return
+	}
+	text = quoteWord(text, c.menu.quote)
+	line := c.menu.origLine
+	c.currline = line[:c.menu.start] + text + line[c.menu.origCursor:]
+	c.cursor = c.menu.start + len(text)
+}
+
+// menuNext selects the next suggestion in the completion menu.
+func (c *Console) menuNext() {
+	c.menuSelect(1)
+}
+
+// menuPrev selects the previous suggestion in the completion menu.
+func (c *Console) menuPrev() {
+	c.menuSelect(-1)
+}
+
+// completeBackward opens the completion menu, if it isn't already shown, and
+// selects its last suggestion.
+func (c *Console) completeBackward() {
+	c.doTabCompletion()
+	if c.menu.active {
+		c.menuPrev()
+	}
+}
+
+// closeMenu hides the completion menu, keeping any suggestion selected.
+func (c *Console) closeMenu() {
+	c.menu.active = false
+	c.menu.suggestions = nil
+}
+
+// cancelMenu hides the completion menu, restoring the line as it was when the
+// menu was opened.
+func (c *Console) cancelMenu() {
+	c.currline, c.cursor = c.menu.origLine, c.menu.origCursor
+	c.closeMenu()
+}
+
+// handleMenuAction handles an action performed while the completion menu is
+// shown, returning whether it was handled. Completion and moving through the
+// history move through the menu instead, and accepting the line accepts the
+// selected suggestion. Any other action closes the menu, then is performed as
+// usual.
+func (c *Console) handleMenuAction(name string) bool {
+	switch name {
+	case "complete", "next-history":
+		c.menuNext()
+	case "menu-complete-backward", "previous-history":
+		c.menuPrev()
+	case "accept-line":
+		c.closeMenu()
+	default:
+		c.closeMenu()
+		return false
+	}
+	return true
+}
+
+// menuPlace decides where to show a menu of n rows, given the row the cursor is
+// on, counted from the top of the console. Menus are shown just below the
+// cursor, or just above it if there is more room there, and are cut short to
+// fit. It returns the first row of the menu and how many rows it has.
+func (c *Console) menuPlace(cursorRow, n int) (first, rows int) {
+	rows = n
+	if rows > menuRows {
+		rows = menuRows
+	}
+	first = cursorRow + 1
+	if below, above := c.height-first, cursorRow; rows > below && above > below {
+		if rows > above {
+			rows = above
+		}
+		first = cursorRow - rows
+	} else if rows > below {
+		rows = below
+	}
+	return first, rows
+}
+
+// drawStatus draws a single row of text where the completion menu would be
+// shown, given the row the cursor is on.
+func (c *Console) drawStatus(cursorRow int, status string) {
+	first, rows := c.menuPlace(cursorRow, 1)
+	if rows <= 0 {
+		return
+	}
+	y := c.top + first
+	for x := c.left; x < c.left+c.width; x++ {
+		c.setCell(x, y, ' ', menuDescFmt, tb.ColorDefault)
+	}
+	x := c.left
+	for j := 0; j < len(status) && x < c.left+c.width; {
+		next := nextBoundary(status, j)
+		c.setCell(x, y, firstRune(status[j:next]), menuDescFmt, tb.ColorDefault)
+		x += clusterWidth(status[j:next])
+		j = next
+	}
+}
+
+// drawMenu draws the completion menu just below the row the cursor is on, or
+// just above it if there is more room there. The row is counted from the top
+// of the console.
+func (c *Console) drawMenu(cursorRow int) {
+	n := len(c.menu.suggestions)
+	first, rows := c.menuPlace(cursorRow, n)
+	if rows <= 0 {
+		return
+	}
+
+	// Scroll the menu to keep the selected suggestion shown.
+	if c.menu.selected >= 0 && c.menu.selected < c.menu.top {
+		c.menu.top = c.menu.selected
+	} else if c.menu.selected >= c.menu.top+rows {
+		c.menu.top = c.menu.selected - rows + 1
+	}
+	if c.menu.top > n-rows {
+		c.menu.top = n - rows
+	}
+
+	textCols := 0
+	for _, s := range c.menu.suggestions {
+		if w := textWidth(s.Text); w > textCols {
+			textCols = w
+		}
+	}
+
+	right := c.left + c.width
+	for i := 0; i < rows; i++ {
+		idx := c.menu.top + i
+		s := c.menu.suggestions[idx]
+		y := c.top + first + i
+		fg, descFg := menuFmt, menuDescFmt
+		if idx == c.menu.selected {
+			fg, descFg = menuSelectedFmt, menuSelectedFmt
+		}
+		for x := c.left; x < right; x++ {
+			c.setCell(x, y, ' ', fg, tb.ColorDefault)
+		}
+
+		matched := map[int]bool{}
+		for _, m := range s.Matched {
+			matched[m] = true
+		}
+		x := c.left
+		for j := 0; j < len(s.Text) && x < right; {
+			next := nextBoundary(s.Text, j)
+			cellFg := fg
+			if matched[j] {
+				cellFg |= suggestionMatchFmt
+			}
+			c.setCell(x, y, firstRune(s.Text[j:next]), cellFg, tb.ColorDefault)
+			x += clusterWidth(s.Text[j:next])
+			j = next
+		}
+
+		x = c.left + textCols + menuDescPad
+		for j := 0; j < len(s.Description) && x < right; {
+			next := nextBoundary(s.Description, j)
+			c.setCell(x, y, firstRune(s.Description[j:next]), descFg,
+				tb.ColorDefault)
+			x += clusterWidth(s.Description[j:next])
+			j = next
+		}
+	}
+
+	// Mark the ends of the menu when there are more suggestions beyond them.
+	if c.menu.top > 0 {
+		c.setCell(right-1, c.top+first, '▲', menuFmt, tb.ColorDefault)
+	}
+	if c.menu.top+rows < n {
+		c.setCell(right-1, c.top+first+rows-1, '▼', menuFmt, tb.ColorDefault)
+	}
+}
@@ -0,0 +1,62 @@
+package console
+
+import "testing"
+
+func TestMenu(t *testing.T) {
+	c := NewConsole(0, 0, 80, 10)
+	c.SetCompleter(NewListCompleter([]string{"status", "stash", "show"}))
+	typeText(t, c, "s")
+	pressKeys(t, c, "Tab")
+	if !c.menu.active || len(c.menu.suggestions) != 3 || c.menu.selected != -1 {
+		t.Fatalf("menu = %+v, want 3 suggestions, none selected", c.menu)
+	}
+	checkLine(t, c, "s", 1)
+
+	// Completion moves through the menu, wrapping around at either end.
+	pressKeys(t, c, "Tab")
+	checkLine(t, c, "show", 4)
+	pressKeys(t, c, "Tab Down")
+	checkLine(t, c, "status", 6)
+	pressKeys(t, c, "Tab")
+	checkLine(t, c, "show", 4)
+	pressKeys(t, c, "M-[ Z")
+	checkLine(t, c, "status", 6)
+
+	// Cancelling the menu restores the line.
+	pressKeys(t, c, "C-g")
+	if c.menu.active {
+		t.Error("menu still active after C-g")
+	}
+	checkLine(t, c, "s", 1)
+
+	// Accepting the line accepts the selected suggestion, without executing.
+	pressKeys(t, c, "Tab Up Enter")
+	if c.menu.active {
+		t.Error("menu still active after Enter")
+	}
+	checkLine(t, c, "status", 6)
+
+	// Other keys close the menu, then act as usual.
+	c.SetLine("s")
+	pressKeys(t, c, "Tab Tab x")
+	if c.menu.active {
+		t.Error("menu still active after typing")
+	}
+	checkLine(t, c, "showx", 5)
+}
+
+func TestMenuWithinLine(t *testing.T) {
+	c := NewConsole(0, 0, 80, 10)
+	c.SetCompleter(NewCommandCompleter(&Command{
+		Name: "cat",
+		Args: []Completer{NewListCompleter([]string{"my file", "my notes"})},
+	}))
+	c.SetLine("cat 'm tail")
+	c.SetCursor(6)
+	pressKeys(t, c, "Tab")
+	checkLine(t, c, "cat 'my  tail", 8)
+	pressKeys(t, c, "Tab")
+	checkLine(t, c, "cat 'my file tail", 12)
+	pressKeys(t, c, "Tab")
+	checkLine(t, c, "cat 'my notes tail", 13)
+}
@@ -0,0 +1,227 @@
+package console
+
+// console_model.go contains the operations that edit the console's in-memory
+// model: the scrollback of past output, and the line the user is editing along
+// with the cursor's place in it. None of them touch the terminal, which is
+// painted from the model by render, in console_display.go.
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	tb "github.com/nsf/termbox-go"
+)
+
+// span formats the text of a line between two offsets, in bytes.
+type span struct {
+	start, end int
+	fg, bg     tb.Attribute
+}
+
+// spanFmt returns the foreground and background attributes of the text at the
+// given offset into a line with the given formatting.
+func spanFmt(spans []span, offset int) (fg, bg tb.Attribute) {
+	fg, bg = tb.ColorDefault, tb.ColorDefault
+	for _, s := range spans {
+		if offset >= s.start && offset < s.end {
+			fg, bg = s.fg, s.bg
+		}
+	}
+	return fg, bg
+}
+
+// Print prints a string to a given Console. Any newlines in the string end
+// the current line of output, while text after the last newline is left on
+// the current line, and will be followed by the prompt if nothing else is
+// printed.
+//
+// ANSI escape sequences setting colors and attributes, such as those written
+// by programs coloring their output for a terminal, style the text that
+// follows them, up until they are reset, even across calls to Print. See
+// PrintStyled for printing text in a given style.
+//
+// Print is safe to use from any goroutine, as Writer is. It may also be used
+// from within an Action, or any other hook the console calls while it is held,
+// such as a Completer or a Highlighter, in which case the string is printed
+// once the hook returns.
+func (c *Console) Print(str string) {
+	// Should drawing fail, the console's loop reports the error when it next
+	// draws.
+	c.print(str, nil)
+}
+
+// Println prints a string, followed by a newline, to a given Console.
+func (c *Console) Println(str string) {
+	c.Print(str + "\n")
+}
+
+// printFmt prints a string, as Print does, drawn with the given foreground
+// and background attributes, without looking for escape sequences in it.
+func (c *Console) printFmt(str string, fg, bg tb.Attribute) {
+	parts := strings.Split(str, "\n")
+	for i, part := range parts {
+		if i > 0 {
+			c.endLine()
+		}
+		c.writePartial(part, fg, bg)
+	}
+}
+
+// writePartial writes text, which mustn't contain newlines, to the output on
+// the current line at the output cursor, drawn with the given foreground and
+// background attributes. Any output already after the output cursor is
+// overwritten, a character at a time.
+func (c *Console) writePartial(text string, fg, bg tb.Attribute) {
+	if len(text) == 0 {
+		return
+	}
+	start, end := c.outCursor, c.outCursor
+	for i := 0; i < len(text) && end < len(c.partial); i = nextBoundary(text, i) {
+		end = nextBoundary(c.partial, end)
+	}
+	c.partial = c.partial[:start] + text + c.partial[end:]
+	c.outCursor = start + len(text)
+
+	// Cut the overwritten output out of the formatting, moving the formatting
+	// of anything after it along to match.
+	shift := len(text) - (end - start)
+	spans := []span{}
+	for _, s := range c.partialSpans {
+		if s.start < start {
+			before := s
+			if before.end > start {
+				before.end = start
+			}
+			spans = append(spans, before)
+		}
+		if s.end > end {
+			after := s
+			if after.start < end {
+				after.start = end
+			}
+			after.start, after.end = after.start+shift, after.end+shift
+			spans = append(spans, after)
+		}
+	}
+	if fg != tb.ColorDefault || bg != tb.ColorDefault {
+		spans = append(spans, span{start, c.outCursor, fg, bg})
+	}
+	c.partialSpans = spans
+}
+
+// endLine finishes the current line of output, moving it into the scrollback.
+func (c *Console) endLine() {
+	c.lines = append(c.lines, c.partial)
+	c.lineSpans = append(c.lineSpans, c.partialSpans)
+	c.trimScrollback()
+	c.partial = ""
+	c.partialSpans = nil
+	c.outCursor = 0
+}
+
+// commitLine moves the prompt and the current line into the scrollback, as
+// they were shown, ready for a command's output to follow. The line keeps the
+// formatting given to it by the highlighter.
+func (c *Console) commitLine() {
+	c.highlight()
+	c.outCursor = len(c.partial)
+	c.printFmt(c.promptText(), tb.ColorDefault, tb.ColorDefault)
+	for i := 0; i < len(c.currline); {
+		if c.currline[i] == '\n' {
+			c.printFmt("\n"+c.contPrompt, tb.ColorDefault, tb.ColorDefault)
+			i++
+			continue
+		}
+
+		// Print the run of text sharing the same formatting all at once.
+		fg, bg := spanFmt(c.highlights, i)
+		j := i + 1
+		for j < len(c.currline) && c.currline[j] != '\n' {
+			if f, b := spanFmt(c.highlights, j); f != fg || b != bg {
+				break
+			}
+			j++
+		}
+		c.printFmt(c.currline[i:j], fg, bg)
+		i = j
+	}
+	c.endLine()
+}
+
+// insertChar inserts a character into the current line at the cursor, leaving
+// the cursor after it. A combining character joins the character before it.
+func (c *Console) insertChar(ch rune) {
+	c.currline = c.currline[:c.cursor] + string(ch) + c.currline[c.cursor:]
+	c.cursor += utf8.RuneLen(ch)
+	if c.cursor < len(c.currline) {
+		// Make sure the cursor didn't end up inside a grapheme cluster, in case
+		// the character after it combines with the one just inserted.
+		c.cursor = nextBoundary(c.currline, prevBoundary(c.currline, c.cursor))
+	}
+}
+
+// backspace performs the equivalent of pressing the backspace key, deleting
+// the character before the cursor.
+func (c *Console) backspace() {
+	if c.cursor <= 0 {
+		return
+	}
+	prev := prevBoundary(c.currline, c.cursor)
+	c.currline = c.currline[:prev] + c.currline[c.cursor:]
+	c.cursor = prev
+}
+
+// moveCursorLeft will move the cursor one character to the left, if possible.
+func (c *Console) moveCursorLeft() {
+	c.cursor = prevBoundary(c.currline, c.cursor)
+}
+
+// moveCursorRight will move the cursor one character to the right, if
+// possible.
+func (c *Console) moveCursorRight() {
+	c.cursor = nextBoundary(c.currline, c.cursor)
+}
+
+// Line returns the current line, as the user has typed it so far.
+//
+// Line, SetLine, Cursor, SetCursor and Insert are for actions editing the
+// line, so may only be used from within an Action, or another hook the console
+// calls while it is held, when nothing else can change the line.
+func (c *Console) Line() string {
+	return c.currline
+}
+
+// SetLine replaces the current line, placing the cursor at its end. It may only
+// be used from within an Action.
+func (c *Console) SetLine(line string) {
+	c.currline = line
+	c.cursor = len(line)
+}
+
+// Cursor returns the offset into the current line, in bytes, that the cursor
+// is located at. It may only be used from within an Action.
+func (c *Console) Cursor() int {
+	return c.cursor
+}
+
+// SetCursor moves the cursor to the given offset into the current line, in
+// bytes. Offsets outside of the line are moved to its start or end, and
+// offsets within a character are moved to the start of that character. It may
+// only be used from within an Action.
+func (c *Console) SetCursor(offset int) {
+	c.cursor = 0
+	for c.cursor < len(c.currline) {
+		next := nextBoundary(c.currline, c.cursor)
+		if next > offset {
+			break
+		}
+		c.cursor = next
+	}
+}
+
+// Insert inserts text into the current line at the cursor, leaving the cursor
+// after it. It may only be used from within an Action.
+func (c *Console) Insert(text string) {
+	c.currline = c.currline[:c.cursor] + text + c.currline[c.cursor:]
+	c.cursor += len(text)
+}
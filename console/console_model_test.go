@@ -0,0 +1,190 @@
+package console
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPrint(t *testing.T) {
+	c := NewConsole(0, 0, 20, 5)
+	c.Print("one\ntw")
+	c.Print("o\n\nthree")
+	if want := []string{"one", "two", ""}; !reflect.DeepEqual(c.lines, want) {
+		t.Errorf("lines = %q, want %q", c.lines, want)
+	}
+	if c.partial != "three" {
+		t.Errorf("partial = %q, want %q", c.partial, "three")
+	}
+
+	c.Println("")
+	if c.partial != "" || c.lines[len(c.lines)-1] != "three" {
+		t.Errorf("Println didn't end the line: lines %q, partial %q", c.lines, c.partial)
+	}
+}
+
+func TestScrollbackLimit(t *testing.T) {
+	c := NewConsole(0, 0, 20, 5)
+	c.scrollbackSize = 3
+	for _, line := range []string{"1", "2", "3", "4", "5"} {
+		c.Println(line)
+	}
+	if want := []string{"3", "4", "5"}; !reflect.DeepEqual(c.lines, want) {
+		t.Errorf("lines = %q, want %q", c.lines, want)
+	}
+}
+
+func TestLineEditing(t *testing.T) {
+	c := NewConsole(0, 0, 20, 5)
+	for _, ch := range "helo" {
+		c.insertChar(ch)
+	}
+	c.moveCursorLeft()
+	c.insertChar('l')
+	checkLine(t, c, "hello", 4)
+
+	c.moveCursorRight()
+	c.moveCursorRight()
+	checkLine(t, c, "hello", 5)
+	c.backspace()
+	checkLine(t, c, "hell", 4)
+
+	c.cursor = 0
+	c.backspace()
+	c.moveCursorLeft()
+	checkLine(t, c, "hell", 0)
+}
+
+func TestWrapRows(t *testing.T) {
+	tests := []struct {
+		partial, line string
+		cursor        int
+		rows          int
+		row, col      int
+	}{
+		{"", "", 0, 1, 0, 2},
+		{"", "abc", 3, 1, 0, 5},
+		{"", "abcdefgh", 8, 2, 1, 0},
+		{"", "abcdefgh", 7, 1, 0, 9},
+		{"out", "abcdefghijkl", 2, 2, 0, 7},
+		{"", "abcdefghijklmnopqr", 18, 3, 2, 0},
+	}
+	for _, tt := range tests {
+		c := NewConsole(0, 0, 10, 5)
+		c.Print(tt.partial)
+		c.currline, c.cursor = tt.line, tt.cursor
+		rows, row, col := c.wrapRows()
+		if len(rows) != tt.rows || row != tt.row || col != tt.col {
+			t.Errorf("%q%q at %d: %d rows, cursor at %d,%d, want %d rows, %d,%d",
+				tt.partial, tt.line, tt.cursor, len(rows), row, col, tt.rows, tt.row, tt.col)
+		}
+	}
+}
+
+func TestFirstRowFollowsCursor(t *testing.T) {
+	c := NewConsole(0, 0, 10, 3)
+	c.SetExecuter(&blockExecuter{con: c})
+	c.Println("out")
+	typeText(t, c, "{")
+	for _, line := range []string{"a", "b", "c", "d", "e"} {
+		pressKeys(t, c, "Enter")
+		typeText(t, c, line)
+	}
+
+	// The rows of the current line past the cursor are left out while the
+	// cursor is near its start, rather than the rows the cursor is on.
+	tests := []struct {
+		keys        string
+		first, want int
+	}{
+		{"", 4, 6},
+		{"Up Up Up", 3, 3},
+		{"Up Up", 1, 1},
+		{"Down Down", 3, 3},
+		{"Down Down Down", 4, 6},
+	}
+	for _, tt := range tests {
+		if tt.keys != "" {
+			pressKeys(t, c, tt.keys)
+		}
+		rows, cursorRow, _ := c.wrapRows()
+		first := c.firstRow(len(rows), cursorRow)
+		if first != tt.first || cursorRow != tt.want {
+			t.Errorf("after %q: first row %d, cursor on %d, want %d, %d", tt.keys,
+				first, cursorRow, tt.first, tt.want)
+		}
+		if cursorRow < first || cursorRow >= first+c.height {
+			t.Errorf("after %q: cursor on %d, outside rows %d to %d", tt.keys,
+				cursorRow, first, first+c.height-1)
+		}
+	}
+}
+
+func TestOutputRowsCached(t *testing.T) {
+	c := NewConsole(0, 0, 4, 5)
+	c.SetScrollback(3)
+
+	// check compares the cached rows with the lines of output wrapped afresh.
+	check := func(when string) {
+		t.Helper()
+		rows, cleared := c.outputRows()
+		want, wantCleared := []displayRow{}, 0
+		for i, line := range c.lines {
+			want = appendOutput(want, line, c.lineSpans[i], c.width)
+			if i < c.clearedLines {
+				wantCleared = len(want)
+			}
+		}
+		if !reflect.DeepEqual(rows, want) || cleared != wantCleared {
+			t.Errorf("%s: rows = %v, %d cleared, want %v, %d cleared", when, rows,
+				cleared, want, wantCleared)
+		}
+	}
+
+	c.Println("abcdef")
+	c.Println("gh")
+	check("printed")
+	c.clearScreen()
+	c.Println("ijklmnopq")
+	check("cleared")
+	c.Println("rs")
+	check("trimmed")
+	c.Resize(0, 0, 3, 5)
+	if rows, _ := c.outputRows(); len(rows) != 5 {
+		t.Errorf("rows once resized = %v, want 5 rows", rows)
+	}
+	check("resized")
+
+	// Appending to the rows leaves the cache alone.
+	rows, _ := c.outputRows()
+	_ = append(rows, displayRow{text: "x"})
+	check("appended")
+}
+
+func TestResize(t *testing.T) {
+	c := NewConsole(0, 0, 80, 10)
+
+	// Resizing is safe while keys are handled.
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 100; i++ {
+			c.Resize(1, 2, 40-i, 5)
+		}
+		done <- true
+	}()
+	typeText(t, c, "hello")
+	<-done
+	if c.top != 1 || c.left != 2 || c.width != 1 || c.height != 5 {
+		t.Errorf("rect = %d, %d, %d, %d, want 1, 2, 1, 5", c.top, c.left,
+			c.width, c.height)
+	}
+	checkLine(t, c, "hello", 5)
+}
+
+// checkLine fails the test unless the console's line and cursor are as given.
+func checkLine(t *testing.T, c *Console, line string, cursor int) {
+	t.Helper()
+	if c.currline != line || c.cursor != cursor {
+		t.Errorf("line = %q, cursor %d, want %q, cursor %d", c.currline, c.cursor,
+			line, cursor)
+	}
+}
@@ -0,0 +1,116 @@
+package console
+
+// console_scrollback.go contains utility functions for letting the user page
+// back through past output, without disturbing the current line.
+
+// wheelRows indicates how many rows a single turn of the mouse wheel scrolls.
+const wheelRows = 3
+
+// SetScrollback sets the maximum number of lines of past output that the
+// console remembers for the user to scroll back through. The oldest lines are
+// discarded once there are more than this.
+func (c *Console) SetScrollback(lines int) {
+	if lines < 0 {
+		lines = 0
+	}
+	c.scrollbackSize = lines
+	c.trimScrollback()
+}
+
+// trimScrollback discards the oldest lines of output, if there are more than
+// the console should remember.
+func (c *Console) trimScrollback() {
+	if extra := len(c.lines) - c.scrollbackSize; extra > 0 {
+		c.lines = c.lines[extra:]
+		c.lineSpans = c.lineSpans[extra:]
+		c.wrapped.trim(extra)
+		c.clearedLines -= extra
+		if c.clearedLines < 0 {
+			c.clearedLines = 0
+		}
+	}
+}
+
+// wrapCache holds the rows that the lines of output wrap into at a given
+// width.
+type wrapCache struct {
+
+	// The width the lines were wrapped at.
+	width int
+
+	// The rows of every line wrapped so far, oldest first.
+	rows []displayRow
+
+	// How many rows each line wrapped into.
+	counts []int
+}
+
+// trim discards the rows of the given number of the oldest lines.
+func (w *wrapCache) trim(lines int) {
+	if lines >= len(w.counts) {
+		w.rows, w.counts = nil, nil
+		return
+	}
+	n := 0
+	for _, count := range w.counts[:lines] {
+		n += count
+	}
+	w.rows = w.rows[n:]
+	w.counts = w.counts[lines:]
+}
+
+// outputRows returns the rows that the lines of output wrap into at the
+// console's width, along with how many of them belong to lines cleared from
+// the screen. Only lines printed since it was last called are wrapped, unless
+// the console's width has changed. The rows mustn't be changed, but may be
+// appended to.
+func (c *Console) outputRows() (rows []displayRow, cleared int) {
+	w := &c.wrapped
+	if w.width != c.width {
+		*w = wrapCache{width: c.width}
+	}
+	for i := len(w.counts); i < len(c.lines); i++ {
+		n := len(w.rows)
+		w.rows = appendOutput(w.rows, c.lines[i], c.lineSpans[i], c.width)
+		w.counts = append(w.counts, len(w.rows)-n)
+	}
+	for _, count := range w.counts[:c.clearedLines] {
+		cleared += count
+	}
+	return w.rows[:len(w.rows):len(w.rows)], cleared
+}
+
+// scrollBy scrolls the console back through past output by the given number
+// of rows, or forward towards the current line if rows is negative. Scrolling
+// past the oldest output is caught when the console is rendered.
+func (c *Console) scrollBy(rows int) {
+	c.scrollOffset += rows
+	if c.scrollOffset < 0 {
+		c.scrollOffset = 0
+	}
+}
+
+// pageUp scrolls the console back through past output by a page.
+func (c *Console) pageUp() {
+	c.scrollBy(c.pageRows())
+}
+
+// pageDown scrolls the console forward towards the current line by a page.
+func (c *Console) pageDown() {
+	c.scrollBy(-c.pageRows())
+}
+
+// pageRows returns the number of rows scrolled by a single page, leaving one
+// row of overlap to keep the user's place.
+func (c *Console) pageRows() int {
+	if c.height > 1 {
+		return c.height - 1
+	}
+	return 1
+}
+
+// snapToLive returns the console to showing the current line, if the user had
+// scrolled back through past output.
+func (c *Console) snapToLive() {
+	c.scrollOffset = 0
+}
@@ -0,0 +1,215 @@
+package console
+
+// console_search.go contains incremental history search, in which the console
+// shows the most recent command containing what the user has typed so far.
+
+import (
+	tb "github.com/nsf/termbox-go"
+
+	"github.com/mcprice30/ugcli"
+)
+
+// searchMatchFmt is used in drawing the text that matched a history search.
+const searchMatchFmt = tb.ColorDefault | tb.AttrBold | tb.AttrUnderline
+
+// searchState holds the state of an incremental history search.
+type searchState struct {
+
+	// Indicates whether a search is in progress.
+	active bool
+
+	// Indicates whether the search moves to older commands, rather than newer
+	// ones.
+	reverse bool
+
+	// The text being searched for.
+	query string
+
+	// Indicates whether no command matched the query.
+	failed bool
+
+	// The index of the command in the history that is shown. While it is the
+	// history's length, the line the user was typing is shown.
+	idx int
+
+	// The offset into the current line at which the query matched.
+	matchPos int
+
+	// What the current line was before the search, along with the cursor and
+	// how far up through the history the user was, so that they can be
+	// restored if the search is cancelled.
+	origLine   string
+	origCursor int
+	origDiff   int
+
+	// The text most recently searched for, which is searched for again if a
+	// search is repeated before anything is typed.
+	lastQuery string
+}
+
+// reverseSearch starts searching backwards through the history, or moves to
+// the next older match if a search is already in progress.
+func (c *Console) reverseSearch() {
+	c.startSearch(true)
+}
+
+// forwardSearch starts searching forwards through the history, or moves to
+// the next newer match if a search is already in progress.
+func (c *Console) forwardSearch() {
+	c.startSearch(false)
+}
+
+// startSearch starts an incremental search in the given direction, or repeats
+// the one in progress. Repeating a search before anything is typed searches
+// for the text that was searched for last.
+func (c *Console) startSearch(reverse bool) {
+	if !c.search.active {
+		c.search.active = true
+		c.search.query = ""
+		c.search.failed = false
+		c.search.idx = c.history.Len() + c.diff
+		c.search.matchPos = c.cursor
+		c.search.origLine = c.currline
+		c.search.origCursor = c.cursor
+		c.search.origDiff = c.diff
+		c.search.reverse = reverse
+		return
+	}
+	c.search.reverse = reverse
+	if c.search.query == "" {
+		c.search.query = c.search.lastQuery
+		if c.search.query == "" {
+			return
+		}
+	}
+	c.searchFrom(c.search.idx, true)
+}
+
+// searchFrom shows the first command containing the query, starting from the
+// given command and moving in the search's direction. If skip is set, the
+// given command itself is passed over, along with any that are the same as
+// it. If no command matches, what is shown is left unchanged.
+func (c *Console) searchFrom(idx int, skip bool) {
+	step := 1
+	if c.search.reverse {
+		step = -1
+	}
+
+	current := c.currline
+	if skip {
+		idx += step
+	}
+	for {
+		found, pos := c.history.Search(c.search.query, idx, c.search.reverse)
+		if found < 0 {
+			c.search.failed = true
+			return
+		}
+		if entry := c.history.Entry(found); !skip || entry != current {
+			c.search.idx = found
+			c.search.failed = false
+			c.search.matchPos = pos
+			c.currline, c.cursor = entry, pos
+			return
+		}
+		idx = found + step
+	}
+}
+
+// handleSearchKey handles a key press during an incremental search, returning
+// whether it was handled. Keys that aren't part of the search end it, keeping
+// the command found, and are then handled as usual.
+func (c *Console) handleSearchKey(key ugcli.Chord) bool {
+	c.snapToLive()
+	name, _ := c.keymap.lookup([]ugcli.Chord{key})
+	switch {
+	case name == "reverse-search-history":
+		c.startSearch(true)
+	case name == "forward-search-history":
+		c.startSearch(false)
+	case key.Key == tb.KeyCtrlG:
+		c.cancelSearch()
+	case key.Key == tb.KeyEsc && key.Mod == 0:
+		c.endSearch()
+	case key.Key == tb.KeyBackspace || key.Key == tb.KeyBackspace2:
+		if c.search.query != "" {
+			q := c.search.query
+			c.search.query = q[:prevBoundary(q, len(q))]
+			c.searchAgain()
+		}
+	case key.Mod == 0 && (key.Ch != 0 || key.Key == tb.KeySpace):
+		if key.Ch == 0 {
+			c.search.query += " "
+		} else {
+			c.search.query += string(key.Ch)
+		}
+		c.searchAgain()
+	default:
+		c.endSearch()
+		return false
+	}
+	return true
+}
+
+// searchAgain searches for the query after it has changed, keeping the
+// command shown if it still matches.
+func (c *Console) searchAgain() {
+	if c.search.query == "" {
+		c.search.failed = false
+		c.search.matchPos = c.cursor
+		return
+	}
+	if c.search.idx < c.history.Len() {
+		c.searchFrom(c.search.idx, false)
+	} else {
+		c.searchFrom(c.search.idx, true)
+	}
+}
+
+// endSearch ends the search, leaving the command that was found as the
+// current line. Moving through the history then continues from it.
+func (c *Console) endSearch() {
+	c.search.active = false
+	if c.search.query != "" {
+		c.search.lastQuery = c.search.query
+	}
+	if c.search.idx < c.history.Len() {
+		if c.search.origDiff == 0 {
+			c.oldLineCopy = c.search.origLine
+		}
+		c.diff = c.search.idx - c.history.Len()
+	}
+}
+
+// cancelSearch ends the search, restoring the line that was being edited
+// before it started.
+func (c *Console) cancelSearch() {
+	c.search.active = false
+	if c.search.query != "" {
+		c.search.lastQuery = c.search.query
+	}
+	c.currline, c.cursor = c.search.origLine, c.search.origCursor
+	c.diff = c.search.origDiff
+}
+
+// searchPrompt returns the prompt shown during a search, in place of the
+// usual one.
+func (c *Console) searchPrompt() string {
+	prompt := "(reverse-i-search)`"
+	if !c.search.reverse {
+		prompt = "(i-search)`"
+	}
+	if c.search.failed {
+		prompt = "(failed " + prompt[1:]
+	}
+	return prompt + c.search.query + "': "
+}
+
+// searchMatch returns the offsets into the current line between which the
+// query matched, or two equal offsets if nothing is matched.
+func (c *Console) searchMatch() (start, end int) {
+	if !c.search.active || c.search.failed || c.search.query == "" {
+		return 0, 0
+	}
+	return c.search.matchPos, c.search.matchPos + len(c.search.query)
+}
@@ -0,0 +1,77 @@
+package console
+
+import "testing"
+
+// newSearchConsole returns a console with the given commands in its history.
+func newSearchConsole(entries ...string) *Console {
+	c := NewConsole(0, 0, 80, 10)
+	for _, entry := range entries {
+		c.History().Add(entry)
+	}
+	return c
+}
+
+func TestReverseSearch(t *testing.T) {
+	c := newSearchConsole("git status", "ls", "git commit", "make")
+
+	pressKeys(t, c, "C-r")
+	typeText(t, c, "git")
+	checkLine(t, c, "git commit", 0)
+
+	// Repeating the search moves to older matches, stopping at the oldest.
+	pressKeys(t, c, "C-r")
+	checkLine(t, c, "git status", 0)
+	pressKeys(t, c, "C-r")
+	if !c.search.failed {
+		t.Error("search past the oldest match didn't fail")
+	}
+	checkLine(t, c, "git status", 0)
+
+	// Searching forwards moves back to newer matches.
+	pressKeys(t, c, "C-s")
+	checkLine(t, c, "git commit", 0)
+
+	// Narrowing the query keeps the match shown while it still matches.
+	typeText(t, c, " c")
+	checkLine(t, c, "git commit", 0)
+	pressKeys(t, c, "Backspace Backspace")
+	checkLine(t, c, "git commit", 0)
+
+	// Other keys end the search, keeping the match, then act as usual.
+	pressKeys(t, c, "C-e")
+	if c.search.active {
+		t.Error("search still active after C-e")
+	}
+	checkLine(t, c, "git commit", 10)
+
+	// The history continues from the match.
+	pressKeys(t, c, "Up")
+	checkLine(t, c, "ls", 2)
+}
+
+func TestSearchCancel(t *testing.T) {
+	c := newSearchConsole("echo one", "echo two")
+	c.SetLine("draft")
+
+	pressKeys(t, c, "C-r")
+	typeText(t, c, "one")
+	checkLine(t, c, "echo one", 5)
+	pressKeys(t, c, "C-g")
+	checkLine(t, c, "draft", 5)
+
+	// Starting another search and repeating it at once searches for the same
+	// text again.
+	pressKeys(t, c, "C-r C-r")
+	checkLine(t, c, "echo one", 5)
+}
+
+func TestSearchNoMatch(t *testing.T) {
+	c := newSearchConsole("ls")
+	c.SetLine("draft")
+	pressKeys(t, c, "C-r")
+	typeText(t, c, "zzz")
+	if !c.search.failed {
+		t.Error("search for a missing command didn't fail")
+	}
+	checkLine(t, c, "draft", 5)
+}
@@ -0,0 +1,281 @@
+package console
+
+// console_style.go contains styled output, along with the parsing of the ANSI
+// escape sequences that programs use to color their output in a terminal.
+
+import (
+	"strconv"
+	"strings"
+
+	tb "github.com/nsf/termbox-go"
+
+	"github.com/mcprice30/ugcli"
+)
+
+// escapeChar starts every ANSI escape sequence.
+const escapeChar = '\x1b'
+
+// Style describes how printed text is drawn. The zero Style draws text in the
+// default colors, without any attributes.
+type Style struct {
+
+	// The color of the text itself, such as tb.ColorRed.
+	Fg tb.Attribute
+
+	// The color behind the text.
+	Bg tb.Attribute
+
+	// Any attributes the text is drawn with, such as tb.AttrBold or
+	// tb.AttrUnderline, combined with |.
+	Attrs tb.Attribute
+}
+
+// attributes returns the foreground and background attributes that termbox
+// draws text in the style with.
+func (s Style) attributes() (fg, bg tb.Attribute) {
+	return s.Fg | s.Attrs, s.Bg
+}
+
+// PrintStyled prints a string to a given Console, as Print does, drawn in the
+// given style. Escape sequences within the string change the style from there
+// on, without affecting anything printed afterwards.
+func (c *Console) PrintStyled(str string, style Style) {
+	c.print(str, &style)
+}
+
+// PrintlnStyled prints a string, followed by a newline, to a given Console,
+// drawn in the given style.
+func (c *Console) PrintlnStyled(str string, style Style) {
+	c.PrintStyled(str+"\n", style)
+}
+
+// printStyled prints a string, drawn in the given style, which is updated by
+// any SGR escape sequences (such as "\x1b[31m", for red) within the string.
+// All other escape sequences are left out. A sequence cut off at the end of
+// the string is held until the next string is printed, in case the rest of it
+// follows. Carriage returns, backspaces and tabs move the output cursor, as
+// they would in a terminal.
+func (c *Console) printStyled(str string, style *Style) {
+	str = c.escape + str
+	c.escape = ""
+	for len(str) > 0 {
+		i := strings.IndexAny(str, "\x1b\r\b\t")
+		if i < 0 {
+			i = len(str)
+		}
+		fg, bg := style.attributes()
+		c.printFmt(str[:i], fg, bg)
+		str = str[i:]
+		if len(str) == 0 {
+			break
+		}
+
+		switch str[0] {
+		case '\r':
+			c.outCursor = 0
+			str = str[1:]
+			continue
+		case '\b':
+			c.outCursor = prevBoundary(c.partial, c.outCursor)
+			str = str[1:]
+			continue
+		case '\t':
+			col := textWidth(c.partial[:c.outCursor])
+			c.printFmt(strings.Repeat(" ", tabWidth-col%tabWidth), fg, bg)
+			str = str[1:]
+			continue
+		}
+
+		n, complete := escapeLen(str)
+		if !complete {
+			c.escape = str
+			return
+		}
+		if params, ok := sgrParams(str[:n]); ok {
+			applySGR(style, params, palette256())
+		}
+		str = str[n:]
+	}
+}
+
+// escapeLen returns the length of the escape sequence that str starts with,
+// and whether all of it is there. Control sequences, which start with ESC [,
+// and operating system commands, which start with ESC ] and end with BEL or
+// ESC \, are recognized; any other escape is taken to be two bytes long.
+func escapeLen(str string) (n int, complete bool) {
+	if len(str) < 2 {
+		return len(str), false
+	}
+	switch str[1] {
+	case '[':
+		for i := 2; i < len(str); i++ {
+			switch b := str[i]; {
+			case b >= 0x40 && b <= 0x7e:
+				return i + 1, true
+			case b < 0x20 || b > 0x7e:
+				// Not a valid control sequence, so leave out only what came
+				// before this byte.
+				return i, true
+			}
+		}
+		return len(str), false
+	case ']':
+		for i := 2; i < len(str); i++ {
+			if str[i] == '\a' {
+				return i + 1, true
+			} else if str[i] == escapeChar && i+1 < len(str) && str[i+1] == '\\' {
+				return i + 2, true
+			}
+		}
+		return len(str), false
+	}
+	return 2, true
+}
+
+// sgrParams returns the parameters of an escape sequence, if it is an SGR
+// (select graphic rendition) sequence, such as "\x1b[1;31m". Missing
+// parameters are 0.
+func sgrParams(seq string) (params []int, ok bool) {
+	if len(seq) < 3 || seq[1] != '[' || seq[len(seq)-1] != 'm' {
+		return nil, false
+	}
+	// Some programs separate the parts of extended colors with colons.
+	fields := strings.Split(strings.Replace(seq[2:len(seq)-1], ":", ";", -1), ";")
+	for _, field := range fields {
+		p := 0
+		if field != "" {
+			var err error
+			if p, err = strconv.Atoi(field); err != nil {
+				return nil, false
+			}
+		}
+		params = append(params, p)
+	}
+	return params, true
+}
+
+// applySGR updates a style with the parameters of an SGR escape sequence.
+// Extended colors are drawn from the 256 color palette if palette256 is set.
+func applySGR(style *Style, params []int, palette256 bool) {
+	for i := 0; i < len(params); i++ {
+		switch p := params[i]; {
+		case p == 0:
+			*style = Style{}
+		case p == 1:
+			style.Attrs |= tb.AttrBold
+		case p == 2:
+			style.Attrs |= tb.AttrDim
+		case p == 3:
+			style.Attrs |= tb.AttrCursive
+		case p == 4:
+			style.Attrs |= tb.AttrUnderline
+		case p == 5 || p == 6:
+			style.Attrs |= tb.AttrBlink
+		case p == 7:
+			style.Attrs |= tb.AttrReverse
+		case p == 8:
+			style.Attrs |= tb.AttrHidden
+		case p == 22:
+			style.Attrs &^= tb.AttrBold | tb.AttrDim
+		case p == 23:
+			style.Attrs &^= tb.AttrCursive
+		case p == 24:
+			style.Attrs &^= tb.AttrUnderline
+		case p == 25:
+			style.Attrs &^= tb.AttrBlink
+		case p == 27:
+			style.Attrs &^= tb.AttrReverse
+		case p == 28:
+			style.Attrs &^= tb.AttrHidden
+		case p >= 30 && p <= 37:
+			style.Fg = tb.ColorBlack + tb.Attribute(p-30)
+		case p == 38:
+			color, n := sgrColor(params[i+1:], palette256)
+			style.Fg, i = color, i+n
+		case p == 39:
+			style.Fg = tb.ColorDefault
+		case p >= 40 && p <= 47:
+			style.Bg = tb.ColorBlack + tb.Attribute(p-40)
+		case p == 48:
+			color, n := sgrColor(params[i+1:], palette256)
+			style.Bg, i = color, i+n
+		case p == 49:
+			style.Bg = tb.ColorDefault
+		case p >= 90 && p <= 97:
+			style.Fg = tb.ColorDarkGray + tb.Attribute(p-90)
+		case p >= 100 && p <= 107:
+			style.Bg = tb.ColorDarkGray + tb.Attribute(p-100)
+		}
+	}
+}
+
+// sgrColor returns the extended color given by the parameters following 38 or
+// 48 in an SGR escape sequence, along with how many of them it used. Colors
+// are given either from the 256 color palette, as 5;n, or as 2;r;g;b. With
+// palette256 set, colors are drawn as the nearest color in the 256 color
+// palette, which termbox only draws in its Output256 mode. Otherwise, they are
+// drawn as the nearest of the 16 colors which termbox always draws.
+func sgrColor(params []int, palette256 bool) (color tb.Attribute, n int) {
+	var idx, r, g, b int
+	if len(params) >= 2 && params[0] == 5 {
+		idx, n = params[1]&0xff, 2
+		r, g, b = paletteRGB(idx)
+	} else if len(params) >= 4 && params[0] == 2 {
+		r, g, b, n = params[1]&0xff, params[2]&0xff, params[3]&0xff, 4
+		cube := func(v int) int { return (v*5 + 127) / 255 }
+		idx = 16 + 36*cube(r) + 6*cube(g) + cube(b)
+	} else {
+		return tb.ColorDefault, len(params)
+	}
+
+	if !palette256 && idx >= 16 {
+		// Find the nearest of the 16 colors.
+		best := -1
+		for i := 0; i < 16; i++ {
+			pr, pg, pb := paletteRGB(i)
+			d := (pr-r)*(pr-r) + (pg-g)*(pg-g) + (pb-b)*(pb-b)
+			if best < 0 || d < best {
+				idx, best = i, d
+			}
+		}
+	}
+	return tb.Attribute(idx) + 1, n
+}
+
+// basicRGB holds the red, green and blue components of the first 16 colors of
+// the 256 color palette, as xterm draws them.
+var basicRGB = [16][3]int{
+	{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0},
+	{0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+	{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0},
+	{92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+// paletteRGB returns the red, green and blue components of a color in the 256
+// color palette: 16 basic colors, then a 6x6x6 color cube, then 24 shades of
+// gray.
+func paletteRGB(idx int) (r, g, b int) {
+	switch {
+	case idx < 16:
+		return basicRGB[idx][0], basicRGB[idx][1], basicRGB[idx][2]
+	case idx < 232:
+		level := func(v int) int {
+			if v == 0 {
+				return 0
+			}
+			return 55 + 40*v
+		}
+		idx -= 16
+		return level(idx / 36), level(idx / 6 % 6), level(idx % 6)
+	}
+	gray := 8 + 10*(idx-232)
+	return gray, gray, gray
+}
+
+// palette256 indicates whether termbox draws colors from the 256 color
+// palette, rather than only the 16 basic colors.
+func palette256() bool {
+	ugcli.LockScreen()
+	defer ugcli.UnlockScreen()
+	return tb.SetOutputMode(tb.OutputCurrent) == tb.Output256
+}
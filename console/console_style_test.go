@@ -0,0 +1,127 @@
+package console
+
+import (
+	"testing"
+
+	tb "github.com/nsf/termbox-go"
+)
+
+func TestEscapeLen(t *testing.T) {
+	tests := []struct {
+		str      string
+		n        int
+		complete bool
+	}{
+		{"\x1b", 1, false},
+		{"\x1b[", 2, false},
+		{"\x1b[31", 4, false},
+		{"\x1b[31mred", 5, true},
+		{"\x1b[mx", 3, true},
+		{"\x1b[?25lx", 6, true},
+		{"\x1b[3\n1m", 3, true},
+		{"\x1b]0;title\ax", 10, true},
+		{"\x1b]0;title\x1b\\x", 11, true},
+		{"\x1b]0;tit", 7, false},
+		{"\x1bcx", 2, true},
+	}
+	for _, tt := range tests {
+		n, complete := escapeLen(tt.str)
+		if n != tt.n || complete != tt.complete {
+			t.Errorf("escapeLen(%q) = %d, %v, want %d, %v", tt.str, n, complete,
+				tt.n, tt.complete)
+		}
+	}
+}
+
+func TestApplySGR(t *testing.T) {
+	red := Style{Fg: tb.ColorRed}
+	tests := []struct {
+		start      Style
+		seq        string
+		palette256 bool
+		want       Style
+	}{
+		{Style{}, "\x1b[31m", false, red},
+		{red, "\x1b[0m", false, Style{}},
+		{red, "\x1b[m", false, Style{}},
+		{Style{}, "\x1b[1;4;42m", false,
+			Style{Bg: tb.ColorGreen, Attrs: tb.AttrBold | tb.AttrUnderline}},
+		{Style{Attrs: tb.AttrBold | tb.AttrDim | tb.AttrUnderline}, "\x1b[22m", false,
+			Style{Attrs: tb.AttrUnderline}},
+		{Style{Fg: tb.ColorRed, Bg: tb.ColorBlue}, "\x1b[39;49m", false, Style{}},
+		{Style{}, "\x1b[91;104m", false, Style{Fg: tb.ColorLightRed, Bg: tb.ColorLightBlue}},
+		{Style{}, "\x1b[38;5;196m", true, Style{Fg: 197}},
+		{Style{}, "\x1b[38;5;196m", false, Style{Fg: tb.ColorLightRed}},
+		{Style{}, "\x1b[38:5:2m", false, Style{Fg: tb.ColorGreen}},
+		{Style{}, "\x1b[48;2;255;255;255;1m", false,
+			Style{Bg: tb.ColorLightGray, Attrs: tb.AttrBold}},
+		{Style{}, "\x1b[48;2;0;0;0m", true, Style{Bg: 17}},
+		{Style{}, "\x1b[;1m", false, Style{Attrs: tb.AttrBold}},
+	}
+	for _, tt := range tests {
+		params, ok := sgrParams(tt.seq)
+		if !ok {
+			t.Errorf("sgrParams(%q) failed", tt.seq)
+			continue
+		}
+		style := tt.start
+		applySGR(&style, params, tt.palette256)
+		if style != tt.want {
+			t.Errorf("applySGR(%+v, %q, %v) = %+v, want %+v", tt.start, tt.seq,
+				tt.palette256, style, tt.want)
+		}
+	}
+
+	for _, seq := range []string{"\x1b[?1m", "\x1b[2J", "\x1b]0;x\a"} {
+		if params, ok := sgrParams(seq); ok {
+			t.Errorf("sgrParams(%q) = %v, want not SGR", seq, params)
+		}
+	}
+}
+
+func TestPrintEscapes(t *testing.T) {
+	c := NewConsole(0, 0, 80, 10)
+	c.Print("a\x1b[31mred\x1b")
+	c.Print("[0m plain\tx\n")
+	c.Print("50%\r99%\nab\bX\n")
+
+	wantLines := []string{"ared plain      x", "99%", "aX"}
+	for i, want := range wantLines {
+		if c.lines[i] != want {
+			t.Errorf("line %d = %q, want %q", i, c.lines[i], want)
+		}
+	}
+	if fg, _ := spanFmt(c.lineSpans[0], 1); fg != tb.ColorRed {
+		t.Errorf("foreground of red = %v, want %v", fg, tb.ColorRed)
+	}
+	if fg, _ := spanFmt(c.lineSpans[0], 4); fg != tb.ColorDefault {
+		t.Errorf("foreground of plain = %v, want %v", fg, tb.ColorDefault)
+	}
+}
+
+func TestPrintStyled(t *testing.T) {
+	c := NewConsole(0, 0, 80, 10)
+	bold := Style{Fg: tb.ColorYellow, Attrs: tb.AttrBold}
+	c.Print("warning: ")
+	c.PrintlnStyled("disk \x1b[4mfull", bold)
+	c.Println("done")
+
+	if c.lines[0] != "warning: disk full" {
+		t.Fatalf("line 0 = %q, want %q", c.lines[0], "warning: disk full")
+	}
+	tests := []struct {
+		line, offset int
+		fg           tb.Attribute
+	}{
+		{0, 0, tb.ColorDefault},
+		{0, 9, tb.ColorYellow | tb.AttrBold},
+		{0, 14, tb.ColorYellow | tb.AttrBold | tb.AttrUnderline},
+		{1, 0, tb.ColorDefault},
+	}
+	for _, tt := range tests {
+		if fg, _ := spanFmt(c.lineSpans[tt.line], tt.offset); fg != tt.fg {
+			t.Errorf("foreground of line %d at %d = %v, want %v", tt.line, tt.offset,
+				fg, tt.fg)
+		}
+	}
+}
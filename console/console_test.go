@@ -0,0 +1,32 @@
+package console
+
+import (
+	"testing"
+
+	"github.com/mcprice30/ugcli"
+)
+
+// pressKeys sends keys, written as described by ugcli.ParseKeys, to the
+// console, holding it as its loop would.
+func pressKeys(t *testing.T, c *Console, keys string) {
+	t.Helper()
+	chords, err := ugcli.ParseKeys(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.lock()
+	defer c.unlock()
+	for _, key := range chords {
+		c.handleKey(key)
+	}
+}
+
+// typeText sends every character of text to the console, as if typed.
+func typeText(t *testing.T, c *Console, text string) {
+	t.Helper()
+	c.lock()
+	defer c.unlock()
+	for _, ch := range text {
+		c.handleKey(ugcli.Chord{Ch: ch})
+	}
+}
@@ -14,6 +14,20 @@ type Executer interface {
 	BoundConsole() *Console
 }
 
+// MultiLineExecuter is an Executer which allows commands to span several
+// lines, such as a statement in a query language or a block of JSON. When
+// enter is pressed, the console only executes the command if it is complete.
+// Otherwise, a new line is started within the command, shown after the
+// console's continuation prompt, and the user can move between its lines
+// with the up/down arrows.
+type MultiLineExecuter interface {
+	Executer
+
+	// IsComplete indicates whether input, which may contain newlines, is a
+	// complete command, ready to be executed.
+	IsComplete(input string) bool
+}
+
 // DefaultExecuter will produce a builtin executer that supports the "exit"
 // command, and otherwise prints out whatever was just executed. The executer
 // will be bound to whatever console it was created with.
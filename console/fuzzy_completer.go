@@ -0,0 +1,192 @@
+package console
+
+// fuzzy_completer.go contains a completer which matches the characters typed
+// anywhere within its words, in order, ranking the matches by how well they
+// fit.
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Scores given to each character matched by the fuzzy completer. Matches
+// score more if their characters start words, follow one another, or are the
+// same case as typed, and less for every character skipped between them.
+const (
+	fuzzyMatchScore      = 1
+	fuzzyBoundaryBonus   = 8
+	fuzzyContiguousBonus = 5
+	fuzzyCaseBonus       = 1
+	fuzzyGapPenalty      = 1
+)
+
+// fuzzyCompleter is a builtin implementation of the completer interface,
+// which recommends every word in its list containing the typed characters in
+// order.
+type fuzzyCompleter struct {
+
+	// list holds all possible words that the console will tab-complete to.
+	list []string
+}
+
+// NewFuzzyCompleter will take a list of potential command words and produce
+// a completer that returns all elements of the list containing the
+// characters of the given argument in order, though not necessarily next to
+// one another, ignoring case. For example, "gco" matches "git-checkout".
+// Recommendations are ranked best first, favoring characters which start
+// words, follow one another, and are typed in the same case.
+func NewFuzzyCompleter(list []string) Completer {
+	return &fuzzyCompleter{
+		list: list,
+	}
+}
+
+// Complete implements the Completer interface, completing the last argument
+// of the line.
+func (fc *fuzzyCompleter) Complete(input string) (string, []string) {
+	return completeLastArg(fc, input)
+}
+
+// CompleteArg implements the ArgCompleter interface.
+func (fc *fuzzyCompleter) CompleteArg(req CompletionRequest) (string, []string) {
+	prefix, suggestions := fc.Suggest(req)
+	return prefix, suggestionTexts(suggestions)
+}
+
+// Suggest implements the SuggestionCompleter interface. The argument is only
+// replaced when there is a single match, or when every match starts with what
+// was typed, in which case it is expanded to their common prefix.
+func (fc *fuzzyCompleter) Suggest(req CompletionRequest) (string, []Suggestion) {
+	type ranked struct {
+		Suggestion
+		score int
+	}
+	matches := []ranked{}
+	for _, word := range fc.list {
+		if score, matched, ok := fuzzyMatch(req.Word, word); ok {
+			matches = append(matches, ranked{Suggestion{Text: word, Matched: matched}, score})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		} else if len(matches[i].Text) != len(matches[j].Text) {
+			return len(matches[i].Text) < len(matches[j].Text)
+		}
+		return matches[i].Text < matches[j].Text
+	})
+
+	suggestions := make([]Suggestion, len(matches))
+	for i, m := range matches {
+		suggestions[i] = m.Suggestion
+	}
+	options := suggestionTexts(suggestions)
+
+	prefix := req.Word
+	if len(options) == 1 {
+		prefix = options[0]
+	} else if common := commonPrefix(req.Word, options); strings.HasPrefix(common, req.Word) {
+		prefix = common
+	}
+	return prefix, suggestions
+}
+
+// fuzzyMatch finds the best way to match the characters of query within word,
+// in order, ignoring case. It returns the score of that match, along with the
+// offsets into word, in bytes, of the matched characters, or false if word
+// doesn't contain the characters of query in order.
+func fuzzyMatch(query, word string) (score int, matched []int, ok bool) {
+	q := []rune(query)
+	w := []rune(word)
+	if len(q) == 0 {
+		return 0, []int{}, true
+	} else if len(q) > len(w) {
+		return 0, nil, false
+	}
+
+	// best[i][j] holds the best score for matching the first i+1 characters
+	// of the query with the last of them matched at w[j], or -1 if they can't
+	// be matched so. from[i][j] holds where the previous character was
+	// matched, for recovering the positions afterwards.
+	const none = -1 << 30
+	best := make([][]int, len(q))
+	from := make([][]int, len(q))
+	for i := range q {
+		best[i] = make([]int, len(w))
+		from[i] = make([]int, len(w))
+		for j := range w {
+			best[i][j] = none
+			if unicode.ToLower(q[i]) != unicode.ToLower(w[j]) {
+				continue
+			}
+
+			charScore := fuzzyMatchScore
+			if j == 0 || isBoundary(w[j-1], w[j]) {
+				charScore += fuzzyBoundaryBonus
+			}
+			if q[i] == w[j] {
+				charScore += fuzzyCaseBonus
+			}
+
+			if i == 0 {
+				best[i][j] = charScore
+				continue
+			}
+			for k := i - 1; k < j; k++ {
+				if best[i-1][k] == none {
+					continue
+				}
+				s := best[i-1][k] + charScore
+				if k == j-1 {
+					s += fuzzyContiguousBonus
+				} else {
+					s -= fuzzyGapPenalty * (j - k - 1)
+				}
+				if s > best[i][j] {
+					best[i][j], from[i][j] = s, k
+				}
+			}
+		}
+	}
+
+	last := len(q) - 1
+	end := -1
+	for j := range w {
+		if best[last][j] != none && (end < 0 || best[last][j] > best[last][end]) {
+			end = j
+		}
+	}
+	if end < 0 {
+		return 0, nil, false
+	}
+
+	// Recover which characters were matched, converting their positions
+	// from runes to bytes.
+	positions := make([]int, len(q))
+	for i, j := last, end; i >= 0; i-- {
+		positions[i] = j
+		j = from[i][j]
+	}
+	offsets := make([]int, len(w))
+	for j, n := 0, 0; j < len(w); j++ {
+		offsets[j] = n
+		n += utf8.RuneLen(w[j])
+	}
+	matched = make([]int, len(q))
+	for i, j := range positions {
+		matched[i] = offsets[j]
+	}
+	return best[last][end], matched, true
+}
+
+// isBoundary indicates whether the character cur starts a word, given the
+// character prev before it: either prev separates words, or cur starts a word
+// in camel case.
+func isBoundary(prev, cur rune) bool {
+	if !unicode.IsLetter(prev) && !unicode.IsDigit(prev) {
+		return true
+	}
+	return unicode.IsLower(prev) && unicode.IsUpper(cur)
+}
@@ -0,0 +1,58 @@
+package console
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFuzzyMatch(t *testing.T) {
+	tests := []struct {
+		query, word string
+		matched     []int
+		ok          bool
+	}{
+		{"", "anything", []int{}, true},
+		{"gco", "git-checkout", []int{0, 4, 9}, true},
+		{"gco", "go", nil, false},
+		{"abc", "cba", nil, false},
+		{"FB", "fooBar", []int{0, 3}, true},
+		{"é", "café", []int{3}, true},
+	}
+	for _, tt := range tests {
+		_, matched, ok := fuzzyMatch(tt.query, tt.word)
+		if ok != tt.ok || !reflect.DeepEqual(matched, tt.matched) {
+			t.Errorf("fuzzyMatch(%q, %q) = %v, %v, want %v, %v", tt.query, tt.word,
+				matched, ok, tt.matched, tt.ok)
+		}
+	}
+}
+
+func TestFuzzyRanking(t *testing.T) {
+	tests := []struct {
+		word string
+		list []string
+		want []string
+	}{
+		// Characters starting words beat characters scattered within them.
+		{"gc", []string{"magic", "git-commit"}, []string{"git-commit", "magic"}},
+		// Characters next to one another beat characters spread apart.
+		{"at", []string{"xaxt", "xat"}, []string{"xat", "xaxt"}},
+		// Camel case humps count as the starts of words.
+		{"fb", []string{"fable", "fooBar"}, []string{"fooBar", "fable"}},
+		// The same case as typed wins a tie.
+		{"Rm", []string{"rm", "Rm"}, []string{"Rm", "rm"}},
+		// Equally good matches go shortest first.
+		{"ls", []string{"lsblk", "ls"}, []string{"ls", "lsblk"}},
+	}
+	for _, tt := range tests {
+		fc := NewFuzzyCompleter(tt.list).(SuggestionCompleter)
+		_, suggestions := fc.Suggest(CompletionRequest{Word: tt.word})
+		got := []string{}
+		for _, s := range suggestions {
+			got = append(got, s.Text)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ranking of %q for %q = %q, want %q", tt.list, tt.word, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package console
+
+// keymap.go contains the keymap, which decides what a console does in
+// response to each key the user presses.
+
+import (
+	"fmt"
+
+	tb "github.com/nsf/termbox-go"
+
+	"github.com/mcprice30/ugcli"
+)
+
+// Action is an editor action that can be bound to keys within a Keymap. It is
+// called with the console that the keys were pressed in, while the console is
+// held, so it may edit the current line with methods such as Line and Insert.
+// Anything it prints is shown once it returns, but it mustn't read from the
+// console.
+type Action func(c *Console)
+
+// builtinActions holds every action provided by the console, by name. Names
+// follow those used by readline, where there is an equivalent.
+var builtinActions = map[string]Action{
+	"self-insert":            (*Console).selfInsert,
+	"accept-line":            (*Console).executeLine,
+	"interrupt":              (*Console).interrupt,
+	"backward-char":          (*Console).moveCursorLeft,
+	"forward-char":           (*Console).forwardChar,
+	"backward-word":          (*Console).wordBackward,
+	"forward-word":           (*Console).forwardWord,
+	"beginning-of-line":      (*Console).moveToStart,
+	"end-of-line":            (*Console).endOfLine,
+	"backward-delete-char":   (*Console).backspace,
+	"delete-char":            (*Console).deleteForward,
+	"kill-line":              (*Console).killToEnd,
+	"unix-line-discard":      (*Console).killToStart,
+	"unix-word-rubout":       (*Console).killWordBackward,
+	"kill-word":              (*Console).killWordForward,
+	"yank":                   (*Console).yank,
+	"yank-pop":               (*Console).yankPop,
+	"transpose-chars":        (*Console).transposeChars,
+	"clear-screen":           (*Console).clearScreen,
+	"previous-history":       (*Console).doArrowUp,
+	"next-history":           (*Console).doArrowDown,
+	"reverse-search-history": (*Console).reverseSearch,
+	"forward-search-history": (*Console).forwardSearch,
+	"complete":               (*Console).doTabCompletion,
+	"menu-complete-backward": (*Console).completeBackward,
+	"scroll-up":              (*Console).pageUp,
+	"scroll-down":            (*Console).pageDown,
+}
+
+// scrollActions holds the names of actions that page through past output,
+// which, unlike all others, don't return the console to the current line.
+var scrollActions = map[string]bool{
+	"scroll-up":   true,
+	"scroll-down": true,
+}
+
+// defaultBindings holds the key sequences bound by DefaultKeymap, by action.
+var defaultBindings = map[string][]string{
+	"accept-line":            {"Enter"},
+	"interrupt":              {"C-c"},
+	"backward-char":          {"Left", "C-b"},
+	"forward-char":           {"Right", "C-f"},
+	"backward-word":          {"M-b"},
+	"forward-word":           {"M-f"},
+	"beginning-of-line":      {"Home", "C-a"},
+	"end-of-line":            {"End", "C-e"},
+	"backward-delete-char":   {"Backspace", "C-h"},
+	"delete-char":            {"Delete", "C-d"},
+	"kill-line":              {"C-k"},
+	"unix-line-discard":      {"C-u"},
+	"unix-word-rubout":       {"C-w"},
+	"kill-word":              {"M-d"},
+	"yank":                   {"C-y"},
+	"yank-pop":               {"M-y"},
+	"transpose-chars":        {"C-t"},
+	"clear-screen":           {"C-l"},
+	"previous-history":       {"Up", "C-p"},
+	"next-history":           {"Down", "C-n"},
+	"reverse-search-history": {"C-r"},
+	"forward-search-history": {"C-s"},
+	"complete":               {"Tab"},
+	// Terminals send Shift-Tab as Esc [ Z, which arrives as Alt-[ then Z.
+	"menu-complete-backward": {"M-[ Z"},
+	"scroll-up":              {"PgUp"},
+	"scroll-down":            {"PgDn"},
+	"self-insert":            {"Space"},
+}
+
+// binding is a key sequence bound to an action within a keymap.
+type binding struct {
+	keys   []ugcli.Chord
+	action string
+}
+
+// Keymap maps sequences of key chords to named editor actions. A sequence may
+// be a single chord, such as Ctrl-A, or several chords pressed one after
+// another, such as Ctrl-X followed by Ctrl-E.
+//
+// Every builtin action is available by name, and custom actions can be added
+// with RegisterAction. Printable characters which aren't bound to anything
+// insert themselves into the current line.
+type Keymap struct {
+
+	// bindings maps each bound key sequence, encoded by seqKey, to its binding.
+	bindings map[string]binding
+
+	// actions holds custom actions registered with this keymap, by name.
+	actions map[string]Action
+}
+
+// NewKeymap returns a keymap with no keys bound.
+func NewKeymap() *Keymap {
+	return &Keymap{
+		bindings: map[string]binding{},
+		actions:  map[string]Action{},
+	}
+}
+
+// DefaultKeymap returns a new keymap with the console's default, emacs-like
+// bindings, which may then be changed without affecting any other keymap.
+func DefaultKeymap() *Keymap {
+	k := NewKeymap()
+	for action, specs := range defaultBindings {
+		for _, spec := range specs {
+			keys, err := ugcli.ParseKeys(spec)
+			if err != nil {
+				panic(err)
+			}
+			k.bind(keys, action)
+		}
+	}
+	return k
+}
+
+// Bind binds a key sequence, written as described by ugcli.ParseKeys, to the
+// named action, replacing anything it was bound to before. For example,
+// Bind("C-x C-e", "my-action"). It returns an error if the key sequence can't
+// be parsed, or there is no action by that name.
+func (k *Keymap) Bind(keys string, action string) error {
+	chords, err := ugcli.ParseKeys(keys)
+	if err != nil {
+		return err
+	}
+	return k.BindChords(action, chords...)
+}
+
+// BindChords binds a key sequence, given as chords, to the named action,
+// replacing anything it was bound to before. It returns an error if there is
+// no action by that name.
+func (k *Keymap) BindChords(action string, keys ...ugcli.Chord) error {
+	if k.action(action) == nil {
+		return fmt.Errorf("console: unknown action %q", action)
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("console: no keys to bind to %q", action)
+	}
+	k.bind(keys, action)
+	return nil
+}
+
+// Unbind removes the binding for a key sequence, written as described by
+// ugcli.ParseKeys, if there is one.
+func (k *Keymap) Unbind(keys string) error {
+	chords, err := ugcli.ParseKeys(keys)
+	if err != nil {
+		return err
+	}
+	k.UnbindChords(chords...)
+	return nil
+}
+
+// UnbindChords removes the binding for a key sequence, given as chords, if
+// there is one.
+func (k *Keymap) UnbindChords(keys ...ugcli.Chord) {
+	delete(k.bindings, seqKey(keys))
+}
+
+// RegisterAction adds a custom action to the keymap under the given name, so
+// that keys can be bound to it. Registering an action with the same name as a
+// builtin action replaces the builtin action within this keymap.
+func (k *Keymap) RegisterAction(name string, action Action) {
+	k.actions[name] = action
+}
+
+// bind binds a key sequence to the named action.
+func (k *Keymap) bind(keys []ugcli.Chord, action string) {
+	k.bindings[seqKey(keys)] = binding{
+		keys:   append([]ugcli.Chord{}, keys...),
+		action: action,
+	}
+}
+
+// action returns the action with the given name, or nil if there is none.
+func (k *Keymap) action(name string) Action {
+	if action, ok := k.actions[name]; ok {
+		return action
+	}
+	return builtinActions[name]
+}
+
+// lookup returns the name of the action bound to the given key sequence, if
+// any, and whether the sequence is the start of some longer bound sequence.
+func (k *Keymap) lookup(keys []ugcli.Chord) (action string, prefix bool) {
+	if b, ok := k.bindings[seqKey(keys)]; ok {
+		action = b.action
+	}
+	for _, b := range k.bindings {
+		if len(b.keys) > len(keys) && seqKey(b.keys[:len(keys)]) == seqKey(keys) {
+			return action, true
+		}
+	}
+	return action, false
+}
+
+// seqKey encodes a key sequence as a string, so that it can be used as a map
+// key.
+func seqKey(keys []ugcli.Chord) string {
+	key := ""
+	for _, k := range keys {
+		key += fmt.Sprintf("%d/%d/%d;", k.Key, k.Ch, k.Mod)
+	}
+	return key
+}
+
+// SetKeymap sets the keymap used to decide what the console does when keys
+// are pressed.
+func (c *Console) SetKeymap(k *Keymap) {
+	c.keymap = k
+}
+
+// Keymap returns the keymap used by the console, which may be changed to
+// rebind its keys.
+func (c *Console) Keymap() *Keymap {
+	return c.keymap
+}
+
+// handleKey adds a key press to any pending key sequence, then runs whatever
+// action the sequence is bound to. If the sequence is only the start of a
+// bound sequence, the console waits for the rest of it.
+func (c *Console) handleKey(key ugcli.Chord) {
+	c.async.err = nil
+	if c.search.active && len(c.pendingKeys) == 0 && c.handleSearchKey(key) {
+		return
+	}
+	if c.menu.active && len(c.pendingKeys) == 0 && key.Mod == 0 &&
+		(key.Key == tb.KeyEsc || key.Key == tb.KeyCtrlG) && key.Ch == 0 {
+		c.cancelMenu()
+		return
+	}
+	if c.editMode == ViMode && len(c.pendingKeys) == 0 && !c.startsEscSequence(key) &&
+		c.handleViKey(key) {
+		return
+	}
+
+	c.pendingKeys = append(c.pendingKeys, key)
+	name, prefix := c.keymap.lookup(c.pendingKeys)
+	if prefix {
+		return
+	}
+
+	keys := c.pendingKeys
+	c.pendingKeys = nil
+	if name != "" {
+		c.runAction(name, key)
+	} else if len(keys) > 1 {
+		// The sequence turned out not to be bound. If what came before this key
+		// was bound by itself, act on it, then start again from this key.
+		if prev, _ := c.keymap.lookup(keys[:len(keys)-1]); prev != "" {
+			c.runAction(prev, keys[len(keys)-2])
+		} else if c.editMode == ViMode && len(keys) == 2 {
+			// The first key was Esc followed by a character after all.
+			c.handleViKey(keys[0])
+		}
+		c.handleKey(key)
+	} else if key.Ch != 0 && key.Mod == 0 {
+		// Unbound printable characters insert themselves.
+		c.runAction("self-insert", key)
+	}
+}
+
+// startsEscSequence reports whether a key starts a bound sequence sent by the
+// terminal as an escape sequence, such as Shift-Tab, which arrives as Alt-[
+// then Z. In vi mode, such keys are left to the keymap, rather than being
+// taken as Esc followed by a character.
+func (c *Console) startsEscSequence(key ugcli.Chord) bool {
+	if key.Mod != tb.ModAlt {
+		return false
+	}
+	_, prefix := c.keymap.lookup([]ugcli.Chord{key})
+	return prefix
+}
+
+// runAction runs the named action, in response to the given key.
+func (c *Console) runAction(name string, key ugcli.Chord) {
+	action := c.keymap.action(name)
+	if action == nil {
+		return
+	}
+
+	// Any action, other than those for paging through past output, brings the
+	// user back to the current line.
+	if !scrollActions[name] {
+		c.snapToLive()
+	}
+	c.lastKey = key
+	c.prevEdit, c.lastEdit = c.lastEdit, editOther
+	if c.menu.active && c.handleMenuAction(name) {
+		return
+	}
+	action(c)
+	if c.editMode == ViMode {
+		c.viClampCursor()
+	}
+}
+
+// selfInsert inserts the character of the key that was just pressed.
+func (c *Console) selfInsert() {
+	if c.lastKey.Ch != 0 {
+		c.insertChar(c.lastKey.Ch)
+	} else if c.lastKey.Key == tb.KeySpace {
+		c.insertChar(' ')
+	}
+}
+
+// interrupt stops the console.
+func (c *Console) interrupt() {
+	c.running = false
+}
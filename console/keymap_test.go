@@ -0,0 +1,74 @@
+package console
+
+import "testing"
+
+func TestKeymapBind(t *testing.T) {
+	c := NewConsole(0, 0, 80, 10)
+	if err := c.Keymap().Bind("C-o", "beginning-of-line"); err != nil {
+		t.Fatal(err)
+	}
+	typeText(t, c, "abc")
+	pressKeys(t, c, "C-o")
+	checkLine(t, c, "abc", 0)
+
+	if err := c.Keymap().Bind("C-o", "no-such-action"); err == nil {
+		t.Error("Bind to an unknown action succeeded")
+	}
+	if err := c.Keymap().Bind("Q-o", "end-of-line"); err == nil {
+		t.Error("Bind of an unparsable key succeeded")
+	}
+}
+
+func TestKeymapUnbind(t *testing.T) {
+	c := NewConsole(0, 0, 80, 10)
+	typeText(t, c, "abc")
+	if err := c.Keymap().Unbind("C-a"); err != nil {
+		t.Fatal(err)
+	}
+	pressKeys(t, c, "C-a")
+	checkLine(t, c, "abc", 3)
+
+	// Unbound printable characters insert themselves.
+	c.Keymap().Unbind("Space")
+	pressKeys(t, c, "Space")
+	checkLine(t, c, "abc", 3)
+	pressKeys(t, c, "d")
+	checkLine(t, c, "abcd", 4)
+}
+
+func TestKeymapSequences(t *testing.T) {
+	c := NewConsole(0, 0, 80, 10)
+	calls := 0
+	c.Keymap().RegisterAction("count", func(c *Console) { calls++ })
+	if err := c.Keymap().Bind("C-x C-e", "count"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Keymap().Bind("C-x", "end-of-line"); err != nil {
+		t.Fatal(err)
+	}
+	typeText(t, c, "abc")
+	pressKeys(t, c, "C-a C-x")
+	if calls != 0 || c.cursor != 0 {
+		t.Fatalf("prefix ran an action: calls = %d, cursor %d", calls, c.cursor)
+	}
+	pressKeys(t, c, "C-e")
+	if calls != 1 || c.cursor != 0 {
+		t.Errorf("C-x C-e: calls = %d, cursor %d, want 1, 0", calls, c.cursor)
+	}
+
+	// When the sequence isn't bound, the prefix runs by itself, then the
+	// following key starts again.
+	pressKeys(t, c, "C-a C-x z")
+	if calls != 1 {
+		t.Errorf("C-x z: calls = %d, want 1", calls)
+	}
+	checkLine(t, c, "abcz", 4)
+}
+
+func TestDefaultKeymapIsolated(t *testing.T) {
+	a, b := NewConsole(0, 0, 80, 10), NewConsole(0, 0, 80, 10)
+	a.Keymap().Unbind("C-a")
+	typeText(t, b, "abc")
+	pressKeys(t, b, "C-a")
+	checkLine(t, b, "abc", 0)
+}
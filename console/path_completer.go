@@ -0,0 +1,137 @@
+package console
+
+// path_completer.go contains a completer for paths to files and directories.
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PathOptions configures which paths a completer from NewPathCompleter
+// recommends.
+type PathOptions struct {
+
+	// Dir is the directory that relative paths are relative to. If empty, the
+	// working directory is used.
+	Dir string
+
+	// DirsOnly limits recommendations to directories.
+	DirsOnly bool
+
+	// Extensions, if not empty, limits recommendations to directories and
+	// files ending in one of the given extensions, such as ".go".
+	Extensions []string
+
+	// ShowHidden recommends files and directories whose names start with a
+	// dot even before a dot has been typed.
+	ShowHidden bool
+}
+
+// pathCompleter is a builtin implementation of the completer interface, which
+// completes paths to files and directories.
+type pathCompleter struct {
+
+	// opts decides which paths are recommended.
+	opts PathOptions
+}
+
+// NewPathCompleter produces a completer for paths to files and directories.
+// A leading ~ stands for the user's home directory, and directories are
+// completed with a trailing slash, so that their contents can be completed
+// next. Paths containing spaces or other special characters are quoted or
+// escaped as they are placed into the line.
+//
+// The completer can be used by itself, or to complete particular arguments
+// of a command, such as by placing it in Command.Args.
+func NewPathCompleter(opts PathOptions) Completer {
+	return &pathCompleter{
+		opts: opts,
+	}
+}
+
+// Complete implements the Completer interface, completing the last argument
+// of the line as a path.
+func (pc *pathCompleter) Complete(input string) (string, []string) {
+	return completeLastArg(pc, input)
+}
+
+// CompleteArg implements the ArgCompleter interface. Recommendations are the
+// paths of the matching files and directories, starting with the directory
+// part of the path as typed, so that each can replace the whole argument.
+func (pc *pathCompleter) CompleteArg(req CompletionRequest) (string, []string) {
+	word := req.Word
+	if word == "~" {
+		return "~/", []string{"~/"}
+	}
+
+	// typedDir is the directory part of the path, as typed, while base is the
+	// part of the name typed so far.
+	typedDir, base := "", word
+	if i := strings.LastIndex(word, "/"); i >= 0 {
+		typedDir, base = word[:i+1], word[i+1:]
+	}
+
+	entries, err := os.ReadDir(pc.resolve(typedDir))
+	if err != nil {
+		return word, []string{}
+	}
+
+	options := []string{}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, base) {
+			continue
+		} else if strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".") &&
+			!pc.opts.ShowHidden {
+			continue
+		}
+
+		isDir := entry.IsDir()
+		if entry.Type()&os.ModeSymlink != 0 {
+			info, err := os.Stat(filepath.Join(pc.resolve(typedDir), name))
+			isDir = err == nil && info.IsDir()
+		}
+		if isDir {
+			options = append(options, typedDir+name+"/")
+		} else if pc.matches(name) {
+			options = append(options, typedDir+name)
+		}
+	}
+
+	return commonPrefix(word, options), options
+}
+
+// resolve returns the directory that a directory part of a path, as typed,
+// refers to, expanding a leading ~.
+func (pc *pathCompleter) resolve(typedDir string) string {
+	dir := typedDir
+	if strings.HasPrefix(dir, "~/") {
+		if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, dir[2:])
+		}
+	}
+	if dir == "" {
+		dir = "."
+	}
+	if !filepath.IsAbs(dir) && pc.opts.Dir != "" {
+		dir = filepath.Join(pc.opts.Dir, dir)
+	}
+	return dir
+}
+
+// matches indicates whether a file, rather than a directory, with the given
+// name should be recommended.
+func (pc *pathCompleter) matches(name string) bool {
+	if pc.opts.DirsOnly {
+		return false
+	} else if len(pc.opts.Extensions) == 0 {
+		return true
+	}
+	for _, ext := range pc.opts.Extensions {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
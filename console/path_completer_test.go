@@ -0,0 +1,81 @@
+package console
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// makeTree creates the given files, and any directories holding them, under
+// a new temporary directory, which it returns. Names ending in a slash are
+// created as empty directories.
+func makeTree(t *testing.T, names ...string) string {
+	t.Helper()
+	root := t.TempDir()
+	for _, name := range names {
+		path := filepath.Join(root, name)
+		if name[len(name)-1] == '/' {
+			if err := os.MkdirAll(path, 0700); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestPathCompleter(t *testing.T) {
+	root := makeTree(t, "main.go", "main_test.go", "README", ".hidden",
+		"src/util.go", "my docs/notes.txt")
+	tests := []struct {
+		opts    PathOptions
+		input   string
+		want    string
+		options []string
+	}{
+		{PathOptions{}, "cat ma", "cat main", []string{"main.go", "main_test.go"}},
+		{PathOptions{}, "cat R", "cat README", []string{"README"}},
+		{PathOptions{}, "cat s", "cat src/", []string{"src/"}},
+		{PathOptions{}, "cat src/u", "cat src/util.go", []string{"src/util.go"}},
+		{PathOptions{}, "cat .h", "cat .hidden", []string{".hidden"}},
+		{PathOptions{}, "cat my", `cat my\ docs/`, []string{"my docs/"}},
+		{PathOptions{}, "cat nope/", "cat nope/", []string{}},
+		{PathOptions{DirsOnly: true}, "cd ", "cd ", []string{"my docs/", "src/"}},
+		{PathOptions{Extensions: []string{".txt"}}, "cat 'my docs'/", `cat my\ docs/notes.txt`,
+			[]string{"my docs/notes.txt"}},
+		{PathOptions{Extensions: []string{".go"}}, "cat ", "cat ",
+			[]string{"main.go", "main_test.go", "my docs/", "src/"}},
+		{PathOptions{ShowHidden: true}, "cat ", "cat ",
+			[]string{".hidden", "README", "main.go", "main_test.go", "my docs/", "src/"}},
+	}
+	for _, tt := range tests {
+		tt.opts.Dir = root
+		got, options := NewPathCompleter(tt.opts).Complete(tt.input)
+		sort.Strings(options)
+		if got != tt.want || !reflect.DeepEqual(options, tt.options) {
+			t.Errorf("%+v: Complete(%q) = %q, %q, want %q, %q", tt.opts, tt.input,
+				got, options, tt.want, tt.options)
+		}
+	}
+}
+
+func TestPathCompleterMenu(t *testing.T) {
+	root := makeTree(t, "src/util.go", "src/main.go")
+	c := NewConsole(0, 0, 80, 10)
+	c.SetCompleter(NewPathCompleter(PathOptions{Dir: root}))
+	typeText(t, c, "cat src/")
+
+	// Selecting a suggestion keeps the directory it is in.
+	pressKeys(t, c, "Tab Tab")
+	checkLine(t, c, "cat src/main.go", 15)
+	pressKeys(t, c, "Tab")
+	checkLine(t, c, "cat src/util.go", 15)
+}
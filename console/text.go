@@ -0,0 +1,78 @@
+package console
+
+// text.go contains utility functions for working with text as the user sees
+// it: as grapheme clusters (user-perceived characters, which may be made up of
+// several runes), each of which takes up one or two cells of the terminal.
+
+import (
+	"unicode/utf8"
+
+	"github.com/rivo/uniseg"
+)
+
+// nextBoundary returns the offset into str of the end of the grapheme cluster
+// starting at offset i.
+func nextBoundary(str string, i int) int {
+	if i >= len(str) {
+		return len(str)
+	}
+	cluster, _, _, _ := uniseg.FirstGraphemeClusterInString(str[i:], -1)
+	return i + len(cluster)
+}
+
+// prevBoundary returns the offset into str of the start of the grapheme
+// cluster ending at offset i.
+func prevBoundary(str string, i int) int {
+	prev := 0
+	for j := 0; j < i; j = nextBoundary(str, j) {
+		prev = j
+	}
+	return prev
+}
+
+// clusterWidth returns the number of cells a grapheme cluster takes up.
+// Clusters which would otherwise have no width, such as a lone combining mark,
+// are given a cell of their own, so that the cursor can always reach them.
+func clusterWidth(cluster string) int {
+	if w := uniseg.StringWidth(cluster); w > 0 {
+		return w
+	}
+	return 1
+}
+
+// textWidth returns the number of cells a string takes up.
+func textWidth(str string) int {
+	width := 0
+	for i := 0; i < len(str); {
+		next := nextBoundary(str, i)
+		width += clusterWidth(str[i:next])
+		i = next
+	}
+	return width
+}
+
+// firstRune returns the first rune of a grapheme cluster, which is what is
+// drawn to represent it, since each terminal cell holds a single rune.
+func firstRune(cluster string) rune {
+	r, _ := utf8.DecodeRuneInString(cluster)
+	return r
+}
+
+// wrapLine breaks a line of text into rows no wider than width cells, without
+// splitting any grapheme cluster across rows. An empty line still occupies a
+// single row.
+func wrapLine(line string, width int) []string {
+	rows := []string{}
+	start, col := 0, 0
+	for i := 0; i < len(line); {
+		next := nextBoundary(line, i)
+		w := clusterWidth(line[i:next])
+		if col > 0 && col+w > width {
+			rows = append(rows, line[start:i])
+			start, col = i, 0
+		}
+		col += w
+		i = next
+	}
+	return append(rows, line[start:])
+}
@@ -0,0 +1,79 @@
+package console
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBoundaries(t *testing.T) {
+	// "e" followed by a combining acute accent is one character, as is the
+	// flag, made of two regional indicators.
+	str := "aé🇳🇿b"
+	want := []int{0, 1, 4, 12, 13}
+	got := []int{0}
+	for i := 0; i < len(str); {
+		i = nextBoundary(str, i)
+		got = append(got, i)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("boundaries = %v, want %v", got, want)
+	}
+	for j := len(want) - 1; j > 0; j-- {
+		if prev := prevBoundary(str, want[j]); prev != want[j-1] {
+			t.Errorf("prevBoundary(%d) = %d, want %d", want[j], prev, want[j-1])
+		}
+	}
+}
+
+func TestTextWidth(t *testing.T) {
+	tests := []struct {
+		str   string
+		width int
+	}{
+		{"", 0},
+		{"abc", 3},
+		{"日本", 4},
+		{"é", 1},
+	}
+	for _, tt := range tests {
+		if got := textWidth(tt.str); got != tt.width {
+			t.Errorf("textWidth(%q) = %d, want %d", tt.str, got, tt.width)
+		}
+	}
+}
+
+func TestWrapLine(t *testing.T) {
+	tests := []struct {
+		line  string
+		width int
+		want  []string
+	}{
+		{"", 4, []string{""}},
+		{"abcdef", 4, []string{"abcd", "ef"}},
+		{"abcd", 4, []string{"abcd"}},
+		// A wide character that doesn't fit moves to the next row whole.
+		{"abc日本", 4, []string{"abc", "日本"}},
+		{"aébcd", 2, []string{"aé", "bc", "d"}},
+	}
+	for _, tt := range tests {
+		if got := wrapLine(tt.line, tt.width); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("wrapLine(%q, %d) = %q, want %q", tt.line, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestInsertCombining(t *testing.T) {
+	c := NewConsole(0, 0, 20, 5)
+	for _, ch := range "ae" {
+		c.insertChar(ch)
+	}
+	c.moveCursorLeft()
+	c.moveCursorRight()
+	c.insertChar('́')
+	checkLine(t, c, "aé", 4)
+	c.moveCursorLeft()
+	checkLine(t, c, "aé", 1)
+	c.moveCursorRight()
+	c.backspace()
+	checkLine(t, c, "a", 1)
+}
@@ -0,0 +1,115 @@
+package console
+
+// tokenize.go contains utility functions for splitting a line into arguments,
+// following the quoting rules of a POSIX shell.
+
+import (
+	"strings"
+)
+
+// Token is a single argument within a line, as found by Tokenize.
+type Token struct {
+
+	// Text is the argument, with any quotes and escapes removed.
+	Text string
+
+	// Start and End are the offsets into the line, in bytes, of the argument
+	// as it was typed, including any quotes and escapes.
+	Start, End int
+
+	// Quote is the quote (' or ") left open at the end of the argument, or 0
+	// if all of its quotes were closed.
+	Quote byte
+}
+
+// specialChars holds the characters which must be quoted or escaped to appear
+// in an argument as themselves.
+const specialChars = " \t\n'\"\\$`&;|<>()"
+
+// Tokenize splits a line into arguments, separated by whitespace. As in a
+// POSIX shell, text between single quotes is taken literally, a backslash
+// escapes the character after it, and text between double quotes is taken
+// literally except for backslashes escaping ", \, $ and `.
+func Tokenize(line string) []Token {
+	tokens := []Token{}
+	var text strings.Builder
+	inToken := false
+	start := 0
+	var quote byte
+
+	for i := 0; i < len(line); i++ {
+		ch := line[i]
+		switch {
+		case quote == '\'':
+			if ch == '\'' {
+				quote = 0
+			} else {
+				text.WriteByte(ch)
+			}
+		case quote == '"':
+			if ch == '"' {
+				quote = 0
+			} else if ch == '\\' && i+1 < len(line) &&
+				strings.IndexByte("\"\\$`", line[i+1]) >= 0 {
+				i++
+				text.WriteByte(line[i])
+			} else {
+				text.WriteByte(ch)
+			}
+		case ch == ' ' || ch == '\t' || ch == '\n':
+			if inToken {
+				tokens = append(tokens, Token{text.String(), start, i, 0})
+				text.Reset()
+				inToken = false
+			}
+		default:
+			if !inToken {
+				inToken = true
+				start = i
+			}
+			if ch == '\'' || ch == '"' {
+				quote = ch
+			} else if ch == '\\' && i+1 < len(line) {
+				i++
+				text.WriteByte(line[i])
+			} else {
+				text.WriteByte(ch)
+			}
+		}
+	}
+
+	if inToken {
+		tokens = append(tokens, Token{text.String(), start, len(line), quote})
+	}
+	return tokens
+}
+
+// quoteWord quotes an argument so that Tokenize would read it back as it is.
+// If quote is ' or ", the argument is placed within that kind of quote, which
+// is left open so that more can be typed within it. Otherwise, any special
+// characters are escaped with backslashes.
+func quoteWord(word string, quote byte) string {
+	switch quote {
+	case '\'':
+		return "'" + strings.Replace(word, "'", `'\''`, -1)
+	case '"':
+		var b strings.Builder
+		b.WriteByte('"')
+		for i := 0; i < len(word); i++ {
+			if strings.IndexByte("\"\\$`", word[i]) >= 0 {
+				b.WriteByte('\\')
+			}
+			b.WriteByte(word[i])
+		}
+		return b.String()
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(word); i++ {
+		if strings.IndexByte(specialChars, word[i]) >= 0 {
+			b.WriteByte('\\')
+		}
+		b.WriteByte(word[i])
+	}
+	return b.String()
+}
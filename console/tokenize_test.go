@@ -0,0 +1,51 @@
+package console
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tests := []struct {
+		line string
+		want []Token
+	}{
+		{"", []Token{}},
+		{"  ", []Token{}},
+		{"ls -l", []Token{{"ls", 0, 2, 0}, {"-l", 3, 5, 0}}},
+		{"a  b\tc", []Token{{"a", 0, 1, 0}, {"b", 3, 4, 0}, {"c", 5, 6, 0}}},
+		{`echo 'a b'`, []Token{{"echo", 0, 4, 0}, {"a b", 5, 10, 0}}},
+		{`echo "a \"b\" \n"`, []Token{{"echo", 0, 4, 0}, {`a "b" \n`, 5, 17, 0}}},
+		{`a\ b c`, []Token{{"a b", 0, 4, 0}, {"c", 5, 6, 0}}},
+		{`x'y'"z"`, []Token{{"xyz", 0, 7, 0}}},
+		{`cat 'my fi`, []Token{{"cat", 0, 3, 0}, {"my fi", 4, 10, '\''}}},
+		{`cat "my`, []Token{{"cat", 0, 3, 0}, {"my", 4, 7, '"'}}},
+		{`a\`, []Token{{`a\`, 0, 2, 0}}},
+	}
+	for _, tt := range tests {
+		if got := Tokenize(tt.line); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Tokenize(%q) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestQuoteWordRoundTrip(t *testing.T) {
+	words := []string{
+		"plain", "two words", "it's", `say "hi"`, `back\slash`, "$HOME",
+		"a;b|c&d", "tab\there", "new\nline", "(x)<y>", "`cmd`", "ünïcödé",
+	}
+	for _, word := range words {
+		for _, quote := range []byte{0, '\'', '"'} {
+			quoted := quoteWord(word, quote)
+			tokens := Tokenize(quoted)
+			if len(tokens) != 1 {
+				t.Errorf("Tokenize(quoteWord(%q, %q)) = %v, want one token", word, quote, tokens)
+				continue
+			}
+			if tokens[0].Text != word || tokens[0].Quote != quote {
+				t.Errorf("Tokenize(quoteWord(%q, %q)) = %q with quote %q", word, quote,
+					tokens[0].Text, tokens[0].Quote)
+			}
+		}
+	}
+}
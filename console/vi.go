@@ -0,0 +1,448 @@
+package console
+
+// vi.go contains the console's vi editing mode. In it, the user switches
+// between an insert mode, where keys type text as usual, and a normal mode,
+// where keys move the cursor and operate on the current line, as in vi.
+
+import (
+	"strings"
+	"unicode"
+
+	tb "github.com/nsf/termbox-go"
+
+	"github.com/mcprice30/ugcli"
+)
+
+// EditMode selects the style of line editing that a console uses.
+type EditMode int
+
+const (
+	// EmacsMode edits the line using the console's keymap, which binds
+	// emacs-like keys by default. It is the default mode.
+	EmacsMode EditMode = iota
+
+	// ViMode edits the line as in vi. Each line starts out in insert mode,
+	// where keys behave as in EmacsMode, and Esc switches to normal mode.
+	ViMode
+)
+
+// defaultViInsertIndicator and defaultViNormalIndicator are shown before the
+// prompt in vi mode, to indicate whether the console is in insert or normal
+// mode.
+const (
+	defaultViInsertIndicator = "(ins) "
+	defaultViNormalIndicator = "(cmd) "
+)
+
+// viUndoSize indicates the maximum number of edits that can be undone in vi
+// mode.
+const viUndoSize = 100
+
+// viSnapshot records the current line and the cursor's place in it, so that
+// an edit can be undone.
+type viSnapshot struct {
+	line   string
+	cursor int
+}
+
+// viState holds the state of the vi editing mode.
+type viState struct {
+
+	// normal indicates whether the console is in normal mode, rather than
+	// insert mode.
+	normal bool
+
+	// count is the repeat count typed so far for the next command, or 0 if
+	// none has been typed.
+	count int
+
+	// operator is the operator (d, c or y) waiting for a motion, or 0 if none
+	// is.
+	operator rune
+
+	// opCount is the repeat count typed before the pending operator.
+	opCount int
+
+	// register holds the text most recently deleted, changed or yanked, which
+	// is what p pastes.
+	register string
+
+	// undo holds snapshots taken before each edit, most recent last.
+	undo []viSnapshot
+
+	// insertIndicator is shown before the prompt in insert mode.
+	insertIndicator string
+
+	// normalIndicator is shown before the prompt in normal mode.
+	normalIndicator string
+}
+
+// SetEditMode sets the style of line editing that the console uses.
+func (c *Console) SetEditMode(mode EditMode) {
+	c.editMode = mode
+	c.viReset()
+}
+
+// SetViIndicators sets the text shown before the prompt in vi mode, to
+// indicate whether the console is in insert or normal mode.
+func (c *Console) SetViIndicators(insert, normal string) {
+	c.vi.insertIndicator = insert
+	c.vi.normalIndicator = normal
+}
+
+// promptText returns the prompt as it is shown, including the vi mode
+// indicator in vi mode. During a history search, the search is shown instead.
+// While a reader waits for a line, the prompt is left out.
+func (c *Console) promptText() string {
+	prompt := c.prompt
+	if c.read.pending {
+		prompt = ""
+	}
+	if c.search.active {
+		return c.searchPrompt()
+	} else if c.editMode != ViMode {
+		return prompt
+	} else if c.vi.normal {
+		return c.vi.normalIndicator + prompt
+	}
+	return c.vi.insertIndicator + prompt
+}
+
+// viReset returns vi mode to insert mode, ready for a new line. As when
+// entering insert mode from normal mode, everything typed into the new line
+// until returning to normal mode can be undone as a single edit.
+func (c *Console) viReset() {
+	c.vi.normal = false
+	c.vi.count = 0
+	c.vi.operator = 0
+	c.vi.undo = nil
+	c.viSaveUndo()
+}
+
+// handleViKey handles a key press in vi mode, returning whether it was
+// handled. Keys that aren't handled are left to the keymap.
+func (c *Console) handleViKey(key ugcli.Chord) bool {
+	if !c.vi.normal {
+		if key.Mod == tb.ModAlt {
+			// Esc followed quickly by another key arrives as Alt and that key.
+			key.Mod = 0
+		} else if key.Key != tb.KeyEsc || key.Ch != 0 {
+			return false
+		}
+		c.viNormalMode()
+		if key.Key == tb.KeyEsc && key.Ch == 0 {
+			return true
+		}
+	}
+
+	// Space and backspace move the cursor in normal mode, while Esc cancels
+	// any command being typed. Other keys that aren't characters, such as
+	// Enter, are left to the keymap.
+	switch {
+	case key.Ch != 0:
+	case key.Key == tb.KeySpace:
+		key = ugcli.Chord{Ch: 'l'}
+	case key.Key == tb.KeyBackspace || key.Key == tb.KeyBackspace2:
+		key = ugcli.Chord{Ch: 'h'}
+	case key.Key == tb.KeyEsc:
+		c.vi.count, c.vi.operator = 0, 0
+		return true
+	default:
+		c.vi.count, c.vi.operator = 0, 0
+		return false
+	}
+
+	c.snapToLive()
+	c.handleViNormal(key.Ch)
+	return true
+}
+
+// handleViNormal handles a character typed in normal mode.
+func (c *Console) handleViNormal(ch rune) {
+	if (ch >= '1' && ch <= '9') || (ch == '0' && c.vi.count > 0) {
+		c.vi.count = c.vi.count*10 + int(ch-'0')
+		return
+	}
+	count := c.vi.count
+	if count == 0 {
+		count = 1
+	}
+	c.vi.count = 0
+
+	if op := c.vi.operator; op != 0 {
+		c.vi.operator = 0
+		count *= c.vi.opCount
+		if ch == op {
+			// A doubled operator, such as dd, operates on the whole line.
+			c.viApply(op, 0, len(c.currline))
+			return
+		}
+		pos, inclusive, ok := c.viMotion(ch, count, op)
+		if !ok {
+			return
+		}
+		start, end := c.cursor, pos
+		if end < start {
+			start, end = end, start
+		}
+		if inclusive {
+			end = nextBoundary(c.currline, end)
+		}
+		c.viApply(op, start, end)
+		return
+	}
+
+	switch ch {
+	case 'd', 'c', 'y':
+		c.vi.operator = ch
+		c.vi.opCount = count
+	case 'x':
+		end := c.cursor
+		for i := 0; i < count; i++ {
+			end = nextBoundary(c.currline, end)
+		}
+		c.viApply('d', c.cursor, end)
+	case 'X':
+		start := c.cursor
+		for i := 0; i < count; i++ {
+			start = prevBoundary(c.currline, start)
+		}
+		c.viApply('d', start, c.cursor)
+	case 'D':
+		c.viApply('d', c.cursor, len(c.currline))
+	case 'C':
+		c.viApply('c', c.cursor, len(c.currline))
+	case 'p':
+		c.viPut(count, true)
+	case 'P':
+		c.viPut(count, false)
+	case 'u':
+		c.viUndo()
+	case 'i':
+		c.viInsertMode()
+	case 'a':
+		c.cursor = nextBoundary(c.currline, c.cursor)
+		c.viInsertMode()
+	case 'I':
+		c.cursor = 0
+		c.viInsertMode()
+	case 'A':
+		c.cursor = len(c.currline)
+		c.viInsertMode()
+	case 'k':
+		c.doArrowUp()
+		c.viClampCursor()
+	case 'j':
+		c.doArrowDown()
+		c.viClampCursor()
+	default:
+		if pos, _, ok := c.viMotion(ch, count, 0); ok {
+			c.cursor = pos
+			c.viClampCursor()
+		}
+	}
+}
+
+// viMotion returns where the given motion, repeated count times, moves the
+// cursor to, and whether an operator applied to it should include the
+// character at that position. It returns false if ch isn't a motion. The
+// pending operator, if any, is given as op.
+func (c *Console) viMotion(ch rune, count int, op rune) (pos int, inclusive, ok bool) {
+	pos = c.cursor
+	switch ch {
+	case 'h':
+		for i := 0; i < count; i++ {
+			pos = prevBoundary(c.currline, pos)
+		}
+	case 'l':
+		for i := 0; i < count; i++ {
+			pos = nextBoundary(c.currline, pos)
+		}
+	case 'w':
+		if op == 'c' && pos < len(c.currline) && viClass(c.currline, pos) != 0 {
+			// As in vi, cw within a word changes only to the end of it, like
+			// ce, except that the word the cursor is in counts even when the
+			// cursor is already at its end.
+			pos = c.viEndOfWord(pos)
+			for i := 1; i < count; i++ {
+				pos = c.viWordEnd(pos)
+			}
+			return pos, true, true
+		}
+		for i := 0; i < count; i++ {
+			pos = c.viNextWord(pos)
+		}
+	case 'b':
+		for i := 0; i < count; i++ {
+			pos = c.viPrevWord(pos)
+		}
+	case 'e':
+		for i := 0; i < count; i++ {
+			pos = c.viWordEnd(pos)
+		}
+		inclusive = true
+	case '0':
+		pos = 0
+	case '$':
+		pos = len(c.currline)
+	default:
+		return c.cursor, false, false
+	}
+	return pos, inclusive, true
+}
+
+// viClass classifies the character at offset i of str for vi word motions:
+// whitespace is 0, letters, digits and underscores are 1, and all other
+// characters are 2. A word is a run of characters of class 1 or of class 2.
+func viClass(str string, i int) int {
+	r := firstRune(str[i:])
+	switch {
+	case unicode.IsSpace(r):
+		return 0
+	case unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_':
+		return 1
+	}
+	return 2
+}
+
+// viNextWord returns the offset of the start of the word after offset i.
+func (c *Console) viNextWord(i int) int {
+	line := c.currline
+	if i >= len(line) {
+		return i
+	}
+	if class := viClass(line, i); class != 0 {
+		for i < len(line) && viClass(line, i) == class {
+			i = nextBoundary(line, i)
+		}
+	}
+	for i < len(line) && viClass(line, i) == 0 {
+		i = nextBoundary(line, i)
+	}
+	return i
+}
+
+// viPrevWord returns the offset of the start of the word before offset i.
+func (c *Console) viPrevWord(i int) int {
+	line := c.currline
+	i = prevBoundary(line, i)
+	for i > 0 && viClass(line, i) == 0 {
+		i = prevBoundary(line, i)
+	}
+	if i >= len(line) {
+		return i
+	}
+	class := viClass(line, i)
+	for i > 0 && viClass(line, prevBoundary(line, i)) == class {
+		i = prevBoundary(line, i)
+	}
+	return i
+}
+
+// viWordEnd returns the offset of the last character of the word after
+// offset i.
+func (c *Console) viWordEnd(i int) int {
+	line := c.currline
+	i = nextBoundary(line, i)
+	for i < len(line) && viClass(line, i) == 0 {
+		i = nextBoundary(line, i)
+	}
+	if i >= len(line) {
+		return prevBoundary(line, len(line))
+	}
+	return c.viEndOfWord(i)
+}
+
+// viEndOfWord returns the offset of the last character of the word that
+// offset i is in.
+func (c *Console) viEndOfWord(i int) int {
+	line := c.currline
+	class := viClass(line, i)
+	for {
+		next := nextBoundary(line, i)
+		if next >= len(line) || viClass(line, next) != class {
+			return i
+		}
+		i = next
+	}
+}
+
+// viApply applies an operator to the text between offsets start and end of
+// the current line. The text is saved to the register, then deleted by d,
+// deleted before entering insert mode by c, or left in place by y.
+func (c *Console) viApply(op rune, start, end int) {
+	if start >= end && op != 'c' {
+		return
+	}
+	c.vi.register = c.currline[start:end]
+	if op == 'y' {
+		c.cursor = start
+		return
+	}
+
+	c.viSaveUndo()
+	c.currline = c.currline[:start] + c.currline[end:]
+	c.cursor = start
+	if op == 'c' {
+		c.vi.normal = false
+	} else {
+		c.viClampCursor()
+	}
+}
+
+// viPut pastes the register count times, after the cursor, or before it if
+// after is false, leaving the cursor on the last character pasted.
+func (c *Console) viPut(count int, after bool) {
+	if c.vi.register == "" {
+		return
+	}
+	c.viSaveUndo()
+	if after {
+		c.cursor = nextBoundary(c.currline, c.cursor)
+	}
+	c.Insert(strings.Repeat(c.vi.register, count))
+	c.cursor = prevBoundary(c.currline, c.cursor)
+}
+
+// viSaveUndo saves a snapshot of the current line, so that the edit about to
+// be made can be undone.
+func (c *Console) viSaveUndo() {
+	c.vi.undo = append(c.vi.undo, viSnapshot{c.currline, c.cursor})
+	if len(c.vi.undo) > viUndoSize {
+		c.vi.undo = c.vi.undo[1:]
+	}
+}
+
+// viUndo undoes the most recent edit.
+func (c *Console) viUndo() {
+	if len(c.vi.undo) == 0 {
+		return
+	}
+	last := c.vi.undo[len(c.vi.undo)-1]
+	c.vi.undo = c.vi.undo[:len(c.vi.undo)-1]
+	c.currline, c.cursor = last.line, last.cursor
+	c.viClampCursor()
+}
+
+// viInsertMode switches to insert mode. Everything typed until returning to
+// normal mode is undone as a single edit.
+func (c *Console) viInsertMode() {
+	c.viSaveUndo()
+	c.vi.normal = false
+}
+
+// viNormalMode switches to normal mode, moving the cursor back onto the last
+// character typed, as vi does.
+func (c *Console) viNormalMode() {
+	c.vi.normal = true
+	c.vi.count, c.vi.operator = 0, 0
+	c.cursor = prevBoundary(c.currline, c.cursor)
+}
+
+// viClampCursor keeps the cursor on a character in normal mode, where it may
+// not sit past the end of the line.
+func (c *Console) viClampCursor() {
+	if c.vi.normal && c.cursor >= len(c.currline) {
+		c.cursor = prevBoundary(c.currline, len(c.currline))
+	}
+}
@@ -0,0 +1,94 @@
+package console
+
+import "testing"
+
+func TestViNormal(t *testing.T) {
+	tests := []struct {
+		line   string
+		keys   string
+		want   string
+		cursor int
+	}{
+		{"foo bar baz", "0w", "foo bar baz", 4},
+		{"foo bar baz", "02w", "foo bar baz", 8},
+		{"foo bar baz", "0e", "foo bar baz", 2},
+		{"foo bar baz", "b", "foo bar baz", 8},
+		{"foo bar baz", "0dw", "bar baz", 0},
+		{"foo bar baz", "0d2w", "baz", 0},
+		{"foo bar baz", "0de", " bar baz", 0},
+		{"foo bar baz", "0x", "oo bar baz", 0},
+		{"foo bar baz", "03x", " bar baz", 0},
+		{"foo bar baz", "X", "foo bar bz", 9},
+		{"foo bar baz", "0wD", "foo ", 3},
+		{"foo bar baz", "0cwXY", "XY bar baz", 2},
+		{"o bar baz", "0cwXY", "XY bar baz", 2},
+		{"foo bar baz", "0lcwXY", "fXY bar baz", 3},
+		{"foo bar baz", "02cwXY", "XY baz", 2},
+		{"foo bar", "0cwX", "X bar", 1},
+		{"foo bar", "0ywP", "foo foo bar", 3},
+		{"foo bar", "0dwp", "bfoo ar", 4},
+		{"foo bar", "0dwu", "foo bar", 0},
+		{"foo bar", "0ddu", "foo bar", 0},
+		{"foo bar", "0iX", "Xfoo bar", 1},
+		{"foo bar", "0aX", "fXoo bar", 2},
+		{"foo bar", "0AX", "foo barX", 8},
+		{"foo bar", "IX", "Xfoo bar", 1},
+		{"foo bar", "0$", "foo bar", 6},
+		{"foo bar", "0C", "", 0},
+	}
+	for _, tt := range tests {
+		c := NewConsole(0, 0, 80, 10)
+		c.SetEditMode(ViMode)
+		c.SetLine(tt.line)
+		pressKeys(t, c, "Esc")
+		typeText(t, c, tt.keys)
+		if c.currline != tt.want || c.cursor != tt.cursor {
+			t.Errorf("%q after %q = %q, cursor %d, want %q, cursor %d", tt.line,
+				tt.keys, c.currline, c.cursor, tt.want, tt.cursor)
+		}
+	}
+}
+
+func TestViShiftTab(t *testing.T) {
+	c := NewConsole(0, 0, 80, 10)
+	c.SetEditMode(ViMode)
+	c.SetCompleter(NewListCompleter([]string{"status", "stash", "show"}))
+	typeText(t, c, "s")
+	pressKeys(t, c, "Tab Tab")
+	checkLine(t, c, "show", 4)
+
+	// Shift-Tab moves back through the menu in insert mode, rather than being
+	// taken as Esc, [ and Z.
+	pressKeys(t, c, "M-[ Z")
+	checkLine(t, c, "status", 6)
+	if c.vi.normal {
+		t.Error("Shift-Tab left insert mode")
+	}
+
+	// Esc, then [ followed by a key which doesn't finish the sequence, still
+	// acts as those keys.
+	c.cancelMenu()
+	c.SetLine("foo bar")
+	pressKeys(t, c, "M-[ 0 x")
+	checkLine(t, c, "oo bar", 0)
+	if !c.vi.normal {
+		t.Error("Esc didn't enter normal mode")
+	}
+}
+
+func TestViUndoNewLine(t *testing.T) {
+	c := NewConsole(0, 0, 80, 10)
+	c.SetEditMode(ViMode)
+	typeText(t, c, "abc")
+	pressKeys(t, c, "Esc")
+	typeText(t, c, "u")
+	checkLine(t, c, "", 0)
+
+	// Each line starts afresh.
+	typeText(t, c, "ifoo")
+	pressKeys(t, c, "Enter")
+	typeText(t, c, "bar")
+	pressKeys(t, c, "Esc")
+	typeText(t, c, "uu")
+	checkLine(t, c, "", 0)
+}
@@ -1,28 +1,149 @@
 package ugcli
 
 import (
+	"sync"
+
 	tb "github.com/nsf/termbox-go"
 )
 
+// Event types generated by ugcli itself, rather than by termbox. They are
+// delivered through a component's EventQueue alongside ordinary termbox
+// events, and are numbered well clear of the termbox event types.
+const (
+	// EventFocus is sent to a component when it becomes the focused component,
+	// meaning that it will now receive keyboard input.
+	EventFocus tb.EventType = 0x80 + iota
+
+	// EventBlur is sent to a component when it stops being the focused
+	// component.
+	EventBlur
+
+	// EventLayout is sent to a component when the application's layout assigns
+	// it a new area of the screen, held in the event's Rect. Components placed
+	// by the layout receive this instead of termbox's EventResize.
+	//
+	// Every component receives either an EventLayout or an EventResize as its
+	// very first event, so that it knows where to draw itself.
+	EventLayout
+
+	// EventWake is sent to a component by its own EventQueue's Wake method,
+	// so that work finished in the background can be picked up by the
+	// component's main loop.
+	EventWake
+)
+
+// Event is an event delivered to a component. It wraps the termbox event,
+// along with any extra data carried by ugcli's own event types.
+type Event struct {
+	tb.Event
+
+	// Rect holds the area of the screen assigned to the component, for
+	// EventLayout events.
+	Rect Rect
+}
+
+// newEvent returns an Event of one of ugcli's own types.
+func newEvent(t tb.EventType) Event {
+	return Event{Event: tb.Event{Type: t}}
+}
+
 // EventQueue is used to pass events from termbox to individual ugcli components
 type EventQueue struct {
-	eventBuffer chan tb.Event
+	eventBuffer chan Event
+
+	// done is closed once the component reading from the queue has stopped,
+	// so that nothing waits forever to send it events.
+	done chan struct{}
 }
 
 // newEventQueue will create a new EventQueue object.
 func newEventQueue() *EventQueue {
 	return &EventQueue{
-		eventBuffer: make(chan tb.Event, 10),
+		eventBuffer: make(chan Event, 10),
+		done:        make(chan struct{}),
+	}
+}
+
+// send an event to the queue. Events sent after the component has stopped are
+// dropped.
+func (q *EventQueue) addEvent(e Event) {
+	select {
+	case q.eventBuffer <- e:
+	case <-q.done:
 	}
 }
 
-// send an event to the queue.
-func (q *EventQueue) addEvent(e tb.Event) {
-	q.eventBuffer <- e
+// stop marks the component reading from the queue as stopped, releasing
+// anything waiting to send it events.
+func (q *EventQueue) stop() {
+	close(q.done)
+}
+
+// delivery is an event waiting to be sent to a component's queue. Events are
+// sent only once focusLock has been released, since a component with a full
+// queue may itself be waiting for the lock.
+type delivery struct {
+	queue *EventQueue
+	event Event
+}
+
+// deliver sends each event to its queue, in order.
+func deliver(deliveries []delivery) {
+	for _, d := range deliveries {
+		d.queue.addEvent(d.event)
+	}
+}
+
+// Wake sends an EventWake to the queue, so that a component waiting in
+// PollEvent wakes up, such as to show the result of work done in another
+// goroutine. It never blocks: if the queue is full, the component is about to
+// wake up regardless, and no EventWake is sent.
+func (q *EventQueue) Wake() {
+	select {
+	case q.eventBuffer <- newEvent(EventWake):
+	default:
+	}
 }
 
 // PollEvent will block until a new event is added to the queue, at which point
 // it will pass it to the appropriate component.
-func (q *EventQueue) PollEvent() tb.Event {
+func (q *EventQueue) PollEvent() Event {
 	return <-q.eventBuffer
 }
+
+// screenLock serializes access to termbox, which is not safe for concurrent
+// use, between an application and its components.
+var screenLock sync.Mutex
+
+// screenOpen indicates whether an application has initialized the terminal,
+// and not yet restored it. It is guarded by screenLock.
+var screenOpen bool
+
+// LockScreen acquires exclusive access to the terminal. Since every component
+// runs in its own goroutine, components must hold the lock while flushing
+// their drawing to the terminal, so as not to interfere with each other or
+// with the application laying out the screen.
+func LockScreen() {
+	screenLock.Lock()
+}
+
+// UnlockScreen releases the terminal, once acquired with LockScreen.
+func UnlockScreen() {
+	screenLock.Unlock()
+}
+
+// ScreenOpen reports whether the terminal can be drawn on, which it can only
+// be while an application is running. Once an application stops, the
+// terminal is restored, even though components that haven't finished may
+// still be running, so components should check ScreenOpen with the screen
+// locked, and draw nothing if it reports false.
+func ScreenOpen() bool {
+	return screenOpen
+}
+
+// setScreenOpen records whether the terminal can be drawn on.
+func setScreenOpen(open bool) {
+	LockScreen()
+	defer UnlockScreen()
+	screenOpen = open
+}
@@ -0,0 +1,40 @@
+package ugcli
+
+import (
+	"testing"
+	"time"
+
+	tb "github.com/nsf/termbox-go"
+)
+
+func TestAddEventAfterStop(t *testing.T) {
+	q := newEventQueue()
+	for i := 0; i < cap(q.eventBuffer); i++ {
+		q.addEvent(newEvent(tb.EventKey))
+	}
+	q.stop()
+
+	// With the queue full, sending would block forever were the component
+	// still expected to read it.
+	sent := make(chan bool)
+	go func() {
+		q.addEvent(newEvent(tb.EventKey))
+		sent <- true
+	}()
+	select {
+	case <-sent:
+	case <-time.After(5 * time.Second):
+		t.Fatal("addEvent blocked on a stopped queue")
+	}
+}
+
+func TestWakeFullQueue(t *testing.T) {
+	q := newEventQueue()
+	for i := 0; i < cap(q.eventBuffer); i++ {
+		q.Wake()
+	}
+	q.Wake()
+	if n := len(q.eventBuffer); n != cap(q.eventBuffer) {
+		t.Errorf("queue holds %d events, want %d", n, cap(q.eventBuffer))
+	}
+}
@@ -0,0 +1,126 @@
+package ugcli
+
+// focus.go contains the focus manager, which decides which of the
+// components of a Cli application receives keyboard input.
+
+import (
+	tb "github.com/nsf/termbox-go"
+)
+
+// defaultFocusKey is the key chord that cycles focus between components,
+// unless another is chosen with SetFocusKey.
+var defaultFocusKey = Chord{Key: tb.KeyCtrlO}
+
+// SetFocusKey sets the key chord that moves focus to the next component.
+// Presses of this chord are handled by the application itself, and are never
+// delivered to components. It should be called before Run.
+func (c *Cli) SetFocusKey(k Chord) {
+	c.focusKey = k
+}
+
+// Focus moves input focus to the given component. The previously focused
+// component will receive an EventBlur, and the newly focused component an
+// EventFocus. Focusing a component that is unknown or has finished running
+// has no effect.
+func (c *Cli) Focus(h Handle) {
+	c.focusLock.Lock()
+	deliveries := c.setFocus(int(h))
+	c.focusLock.Unlock()
+	deliver(deliveries)
+}
+
+// Focused returns the handle of the component that currently has focus.
+func (c *Cli) Focused() Handle {
+	c.focusLock.Lock()
+	defer c.focusLock.Unlock()
+	return Handle(c.activeComponent)
+}
+
+// FocusNext moves focus to the next running component, in the order they
+// were added, wrapping around after the last.
+func (c *Cli) FocusNext() {
+	c.focusLock.Lock()
+	deliveries := c.setFocus(c.nextRunning(1))
+	c.focusLock.Unlock()
+	deliver(deliveries)
+}
+
+// FocusPrev moves focus to the previous running component, wrapping around
+// before the first.
+func (c *Cli) FocusPrev() {
+	c.focusLock.Lock()
+	deliveries := c.setFocus(c.nextRunning(-1))
+	c.focusLock.Unlock()
+	deliver(deliveries)
+}
+
+// activeHandler returns the event queue of the focused component, or nil if
+// there is no running component to deliver events to.
+func (c *Cli) activeHandler() *EventQueue {
+	c.focusLock.Lock()
+	defer c.focusLock.Unlock()
+	return c.focusedHandler()
+}
+
+// handlerAt returns the event queue of the running component whose area of
+// the screen contains the given cell, falling back to the focused component if
+// the layout does not place any component there.
+func (c *Cli) handlerAt(x, y int) *EventQueue {
+	c.focusLock.Lock()
+	defer c.focusLock.Unlock()
+	for h, r := range c.rects {
+		if !c.stopped[h] && r.Contains(x, y) {
+			return c.handlers[h]
+		}
+	}
+	return c.focusedHandler()
+}
+
+// focusedHandler returns the event queue of the focused component, or nil if
+// it is no longer running.
+// The caller must hold focusLock.
+func (c *Cli) focusedHandler() *EventQueue {
+	if c.activeComponent < 0 || c.stopped[c.activeComponent] {
+		return nil
+	}
+	return c.handlers[c.activeComponent]
+}
+
+// nextRunning returns the index of the first running component found by
+// stepping through the components from the active one, in the direction of
+// step. It returns -1 if no other component is running.
+// The caller must hold focusLock.
+func (c *Cli) nextRunning(step int) int {
+	n := len(c.components)
+	for i := 1; i < n; i++ {
+		next := ((c.activeComponent+step*i)%n + n) % n
+		if !c.stopped[next] {
+			return next
+		}
+	}
+	return -1
+}
+
+// setFocus makes the given component the active one. If the application is
+// running, it returns the events notifying both it and the previously active
+// component, to be delivered once focusLock is released.
+// The caller must hold focusLock.
+func (c *Cli) setFocus(comp int) []delivery {
+	if comp < 0 || comp >= len(c.components) || c.stopped[comp] ||
+		comp == c.activeComponent {
+		return nil
+	}
+
+	prev := c.activeComponent
+	c.activeComponent = comp
+	if !c.running {
+		return nil
+	}
+
+	deliveries := []delivery{}
+	if prev >= 0 && !c.stopped[prev] {
+		deliveries = append(deliveries,
+			delivery{c.handlers[prev], newEvent(EventBlur)})
+	}
+	return append(deliveries, delivery{c.handlers[comp], newEvent(EventFocus)})
+}
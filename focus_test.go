@@ -0,0 +1,101 @@
+package ugcli
+
+import "testing"
+
+// idleComponent is a component which does nothing.
+type idleComponent struct{}
+
+func (idleComponent) Run(*EventQueue) error { return nil }
+
+// newFocusCli returns an application with n components, which isn't running.
+func newFocusCli(n int) *Cli {
+	c := NewCli()
+	for i := 0; i < n; i++ {
+		c.AddComponent(idleComponent{})
+	}
+	return c
+}
+
+// nextEvent returns the type of the next event waiting in q, or -1 if there is
+// none.
+func nextEvent(q *EventQueue) int {
+	if len(q.eventBuffer) == 0 {
+		return -1
+	}
+	return int(q.PollEvent().Type)
+}
+
+func TestFocusCycles(t *testing.T) {
+	c := newFocusCli(3)
+	if got := c.Focused(); got != 0 {
+		t.Fatalf("initial focus = %d, want 0", got)
+	}
+
+	steps := []struct {
+		move func()
+		want Handle
+	}{
+		{c.FocusNext, 1},
+		{c.FocusNext, 2},
+		{c.FocusNext, 0},
+		{c.FocusPrev, 2},
+		{func() { c.Focus(1) }, 1},
+		{func() { c.Focus(7) }, 1},
+		{func() { c.Focus(-1) }, 1},
+	}
+	for i, step := range steps {
+		step.move()
+		if got := c.Focused(); got != step.want {
+			t.Errorf("step %d: focus = %d, want %d", i, got, step.want)
+		}
+	}
+}
+
+func TestFocusSkipsStopped(t *testing.T) {
+	c := newFocusCli(3)
+	c.stopped[1] = true
+
+	c.FocusNext()
+	if got := c.Focused(); got != 2 {
+		t.Errorf("FocusNext = %d, want 2", got)
+	}
+	c.FocusNext()
+	if got := c.Focused(); got != 0 {
+		t.Errorf("FocusNext = %d, want 0", got)
+	}
+	c.Focus(1)
+	if got := c.Focused(); got != 0 {
+		t.Errorf("Focus on a stopped component = %d, want 0", got)
+	}
+
+	c.stopped[2] = true
+	c.FocusNext()
+	if got := c.Focused(); got != 0 {
+		t.Errorf("FocusNext with nothing else running = %d, want 0", got)
+	}
+}
+
+func TestFocusEvents(t *testing.T) {
+	c := newFocusCli(2)
+
+	// Nothing is told about focus changes before the application runs.
+	c.FocusNext()
+	if got := nextEvent(c.handlers[0]); got != -1 {
+		t.Errorf("event before running = %d, want none", got)
+	}
+
+	c.running = true
+	c.FocusNext()
+	if got := nextEvent(c.handlers[1]); got != int(EventBlur) {
+		t.Errorf("previous component got %d, want EventBlur", got)
+	}
+	if got := nextEvent(c.handlers[0]); got != int(EventFocus) {
+		t.Errorf("focused component got %d, want EventFocus", got)
+	}
+
+	// Focusing the focused component changes nothing.
+	c.Focus(0)
+	if got := nextEvent(c.handlers[0]); got != -1 {
+		t.Errorf("event after refocusing = %d, want none", got)
+	}
+}
@@ -0,0 +1,145 @@
+package ugcli
+
+// keys.go contains utilities for describing and matching key presses.
+
+import (
+	"fmt"
+	"strings"
+
+	tb "github.com/nsf/termbox-go"
+)
+
+// Chord describes a single key press, such as Ctrl-O or Alt-F. Printable
+// characters are described by Ch, and all other keys by Key.
+type Chord struct {
+	// Key is the termbox key, used when Ch is 0.
+	Key tb.Key
+
+	// Ch is the character typed, if this is a printable key.
+	Ch rune
+
+	// Mod holds any modifiers, such as tb.ModAlt, that must be held down.
+	Mod tb.Modifier
+}
+
+// Matches reports whether the given termbox event is a press of this chord.
+func (k Chord) Matches(e tb.Event) bool {
+	if e.Type != tb.EventKey || e.Mod != k.Mod {
+		return false
+	}
+	if k.Ch != 0 {
+		return e.Ch == k.Ch
+	}
+	return e.Ch == 0 && e.Key == k.Key
+}
+
+// ChordOf returns the chord pressed in the given key event.
+func ChordOf(e tb.Event) Chord {
+	if e.Ch != 0 {
+		return Chord{Ch: e.Ch, Mod: e.Mod}
+	}
+	return Chord{Key: e.Key, Mod: e.Mod}
+}
+
+// keyNames maps the names accepted by ParseKeys to the keys they describe.
+var keyNames = map[string]tb.Key{
+	"Backspace": tb.KeyBackspace2,
+	"Delete":    tb.KeyDelete,
+	"Down":      tb.KeyArrowDown,
+	"End":       tb.KeyEnd,
+	"Enter":     tb.KeyEnter,
+	"Esc":       tb.KeyEsc,
+	"Home":      tb.KeyHome,
+	"Insert":    tb.KeyInsert,
+	"Left":      tb.KeyArrowLeft,
+	"PgDn":      tb.KeyPgdn,
+	"PgUp":      tb.KeyPgup,
+	"Right":     tb.KeyArrowRight,
+	"Space":     tb.KeySpace,
+	"Tab":       tb.KeyTab,
+	"Up":        tb.KeyArrowUp,
+	"F1":        tb.KeyF1,
+	"F2":        tb.KeyF2,
+	"F3":        tb.KeyF3,
+	"F4":        tb.KeyF4,
+	"F5":        tb.KeyF5,
+	"F6":        tb.KeyF6,
+	"F7":        tb.KeyF7,
+	"F8":        tb.KeyF8,
+	"F9":        tb.KeyF9,
+	"F10":       tb.KeyF10,
+	"F11":       tb.KeyF11,
+	"F12":       tb.KeyF12,
+}
+
+// ParseKeys parses a sequence of key chords, separated by spaces, written in
+// the style of emacs. Each chord is either a single character, such as "x", or
+// the name of a key, such as "Enter", "Tab", "Home", "PgUp" or "F1", and may
+// be prefixed with "C-" to hold Control, or "M-" to hold Alt (meta). Control
+// can only be held with a letter or "Space".
+// For example, "C-x C-e" is Ctrl-X followed by Ctrl-E, and "M-f" is Alt-F.
+func ParseKeys(spec string) ([]Chord, error) {
+	chords := []Chord{}
+	for _, field := range strings.Fields(spec) {
+		chord, err := parseChord(field)
+		if err != nil {
+			return nil, err
+		}
+		chords = append(chords, chord)
+	}
+	if len(chords) == 0 {
+		return nil, fmt.Errorf("ugcli: no keys in %q", spec)
+	}
+	return chords, nil
+}
+
+// parseChord parses a single chord, as described by ParseKeys.
+func parseChord(spec string) (Chord, error) {
+	chord := Chord{}
+	ctrl := false
+	name := spec
+	for len(name) > 2 && name[1] == '-' {
+		switch name[0] {
+		case 'C':
+			ctrl = true
+		case 'M':
+			chord.Mod |= tb.ModAlt
+		default:
+			return chord, fmt.Errorf("ugcli: unknown modifier in key %q", spec)
+		}
+		name = name[2:]
+	}
+
+	if key, ok := keyNames[name]; ok {
+		if ctrl && key == tb.KeySpace {
+			// Terminals send Ctrl-Space as a key of its own.
+			key = tb.KeyCtrlSpace
+		} else if ctrl {
+			return chord, fmt.Errorf("ugcli: can't hold Control with key %q", spec)
+		}
+		chord.Key = key
+		return chord, nil
+	}
+
+	runes := []rune(name)
+	if len(runes) != 1 {
+		return chord, fmt.Errorf("ugcli: unknown key %q", spec)
+	}
+	ch := runes[0]
+	if !ctrl {
+		chord.Ch = ch
+		return chord, nil
+	}
+
+	// Terminals send Control with a letter as the letter's position in the
+	// alphabet, starting from Ctrl-A as 1.
+	switch {
+	case ch >= 'a' && ch <= 'z':
+		chord.Key = tb.KeyCtrlA + tb.Key(ch-'a')
+	case ch >= 'A' && ch <= 'Z':
+		chord.Key = tb.KeyCtrlA + tb.Key(ch-'A')
+	default:
+		return chord, fmt.Errorf("ugcli: can't hold Control with key %q", spec)
+	}
+	return chord, nil
+}
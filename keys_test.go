@@ -0,0 +1,70 @@
+package ugcli
+
+import (
+	"reflect"
+	"testing"
+
+	tb "github.com/nsf/termbox-go"
+)
+
+func TestParseKeys(t *testing.T) {
+	tests := []struct {
+		spec string
+		want []Chord
+	}{
+		{"x", []Chord{{Ch: 'x'}}},
+		{"Enter", []Chord{{Key: tb.KeyEnter}}},
+		{"C-a", []Chord{{Key: tb.KeyCtrlA}}},
+		{"C-E", []Chord{{Key: tb.KeyCtrlE}}},
+		{"C-Space", []Chord{{Key: tb.KeyCtrlSpace}}},
+		{"M-f", []Chord{{Ch: 'f', Mod: tb.ModAlt}}},
+		{"M-Left", []Chord{{Key: tb.KeyArrowLeft, Mod: tb.ModAlt}}},
+		{"C-x C-e", []Chord{{Key: tb.KeyCtrlX}, {Key: tb.KeyCtrlE}}},
+		{"  F1   é ", []Chord{{Key: tb.KeyF1}, {Ch: 'é'}}},
+	}
+	for _, tt := range tests {
+		got, err := ParseKeys(tt.spec)
+		if err != nil {
+			t.Errorf("ParseKeys(%q) failed: %v", tt.spec, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseKeys(%q) = %v, want %v", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestParseKeysErrors(t *testing.T) {
+	for _, spec := range []string{"", "  ", "xy", "Q-x", "C-Enter", "C-1", "C-x Nope"} {
+		if chords, err := ParseKeys(spec); err == nil {
+			t.Errorf("ParseKeys(%q) = %v, want an error", spec, chords)
+		}
+	}
+}
+
+func TestChordMatches(t *testing.T) {
+	key := func(k tb.Key, ch rune, mod tb.Modifier) tb.Event {
+		return tb.Event{Type: tb.EventKey, Key: k, Ch: ch, Mod: mod}
+	}
+	tests := []struct {
+		chord Chord
+		event tb.Event
+		want  bool
+	}{
+		{Chord{Ch: 'x'}, key(0, 'x', 0), true},
+		{Chord{Ch: 'x'}, key(0, 'y', 0), false},
+		{Chord{Ch: 'x'}, key(0, 'x', tb.ModAlt), false},
+		{Chord{Key: tb.KeyCtrlA}, key(tb.KeyCtrlA, 0, 0), true},
+		{Chord{Key: tb.KeyCtrlA}, tb.Event{Type: tb.EventResize}, false},
+		{Chord{Key: tb.KeyCtrlSpace}, key(tb.KeyCtrlSpace, 0, 0), true},
+		{Chord{Key: tb.KeyCtrlSpace}, key(tb.KeySpace, ' ', 0), false},
+	}
+	for _, tt := range tests {
+		if got := tt.chord.Matches(tt.event); got != tt.want {
+			t.Errorf("%v.Matches(%v) = %v, want %v", tt.chord, tt.event, got, tt.want)
+		}
+		if tt.want && ChordOf(tt.event) != tt.chord {
+			t.Errorf("ChordOf(%v) = %v, want %v", tt.event, ChordOf(tt.event), tt.chord)
+		}
+	}
+}
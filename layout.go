@@ -0,0 +1,281 @@
+package ugcli
+
+// layout.go contains the layout manager, which divides the terminal into
+// rectangles and assigns them to the components of an application.
+
+import (
+	tb "github.com/nsf/termbox-go"
+)
+
+// Rect describes a rectangular area of the terminal, in cells. Top and Left
+// are 0-indexed.
+type Rect struct {
+	Top    int
+	Left   int
+	Width  int
+	Height int
+}
+
+// Contains reports whether the given cell lies within the rectangle.
+func (r Rect) Contains(x, y int) bool {
+	return x >= r.Left && x < r.Left+r.Width && y >= r.Top && y < r.Top+r.Height
+}
+
+// sizeKind indicates how the size of a layout within a split is measured.
+type sizeKind int
+
+const (
+	// sizeFlex layouts share whatever space is left over, by weight.
+	sizeFlex sizeKind = iota
+
+	// sizeFixed layouts take up an exact number of cells.
+	sizeFixed
+
+	// sizePercent layouts take up a percentage of their parent split.
+	sizePercent
+)
+
+// Layout is a node in a tree describing how the screen is split among
+// components. The leaves of the tree are panes, each of which holds a single
+// component, while every other node is a split that divides its area among
+// its children, either horizontally or vertically. Splits may be nested to
+// build arbitrary arrangements, and any node may be given a border.
+//
+// Every layout takes up its share of its parent split according to its size,
+// which can be set with Fixed, Percent or Flex. By default, a layout is
+// flexible with a weight of 1.
+type Layout struct {
+
+	// pane is the component occupying this layout, if it is a pane.
+	pane Handle
+
+	// isPane indicates whether this layout is a pane rather than a split.
+	isPane bool
+
+	// vertical indicates that the children of this split are stacked from top
+	// to bottom, rather than placed side by side from left to right.
+	vertical bool
+
+	// children holds the layouts that this split divides its area among.
+	children []*Layout
+
+	// kind indicates how size should be interpreted.
+	kind sizeKind
+
+	// size is the number of cells, percentage or weight of this layout within
+	// its parent split, depending on kind.
+	size int
+
+	// border indicates whether a box should be drawn around this layout.
+	border bool
+
+	// title is displayed in the top edge of the border, if there is one.
+	title string
+}
+
+// Pane returns a layout that assigns all of its area to the given component.
+func Pane(h Handle) *Layout {
+	return &Layout{
+		pane:   h,
+		isPane: true,
+		kind:   sizeFlex,
+		size:   1,
+	}
+}
+
+// HSplit returns a layout that places its children side by side, from left to
+// right, dividing its width among them.
+func HSplit(children ...*Layout) *Layout {
+	return &Layout{
+		children: children,
+		kind:     sizeFlex,
+		size:     1,
+	}
+}
+
+// VSplit returns a layout that stacks its children from top to bottom,
+// dividing its height among them.
+func VSplit(children ...*Layout) *Layout {
+	return &Layout{
+		vertical: true,
+		children: children,
+		kind:     sizeFlex,
+		size:     1,
+	}
+}
+
+// Fixed makes the layout take up exactly the given number of cells within
+// its parent split, space permitting. It returns the layout itself, so that
+// calls can be chained.
+func (l *Layout) Fixed(cells int) *Layout {
+	if cells < 0 {
+		cells = 0
+	}
+	l.kind, l.size = sizeFixed, cells
+	return l
+}
+
+// Percent makes the layout take up the given percentage of its parent split.
+// It returns the layout itself, so that calls can be chained.
+func (l *Layout) Percent(percent int) *Layout {
+	if percent < 0 {
+		percent = 0
+	} else if percent > 100 {
+		percent = 100
+	}
+	l.kind, l.size = sizePercent, percent
+	return l
+}
+
+// Flex makes the layout share whatever space is left in its parent split once
+// all fixed and percentage layouts have been placed, in proportion to the
+// given weight. It returns the layout itself, so that calls can be chained.
+func (l *Layout) Flex(weight int) *Layout {
+	if weight < 1 {
+		weight = 1
+	}
+	l.kind, l.size = sizeFlex, weight
+	return l
+}
+
+// Border draws a box around the layout, with the given title set into its top
+// edge. An empty title draws a plain box. The area inside the box is what
+// remains for the layout's contents. It returns the layout itself, so that
+// calls can be chained.
+func (l *Layout) Border(title string) *Layout {
+	l.border, l.title = true, title
+	return l
+}
+
+// layoutEvent returns an EventLayout assigning the given area to a component.
+func layoutEvent(r Rect) Event {
+	e := newEvent(EventLayout)
+	e.Rect = r
+	return e
+}
+
+// arrange assigns a rectangle to every pane within this layout, given the area
+// of the screen that the layout occupies, and draws any borders. The
+// rectangles are stored in rects, by component.
+func (l *Layout) arrange(r Rect, rects map[Handle]Rect) {
+	if l.border {
+		drawBorder(r, l.title)
+		r = Rect{
+			Top:    r.Top + 1,
+			Left:   r.Left + 1,
+			Width:  r.Width - 2,
+			Height: r.Height - 2,
+		}
+		if r.Width < 0 {
+			r.Width = 0
+		}
+		if r.Height < 0 {
+			r.Height = 0
+		}
+	}
+
+	if l.isPane {
+		rects[l.pane] = r
+		return
+	}
+
+	length := r.Width
+	if l.vertical {
+		length = r.Height
+	}
+
+	offset := 0
+	for i, n := range splitLength(length, l.children) {
+		child := r
+		if l.vertical {
+			child.Top, child.Height = r.Top+offset, n
+		} else {
+			child.Left, child.Width = r.Left+offset, n
+		}
+		l.children[i].arrange(child, rects)
+		offset += n
+	}
+}
+
+// splitLength divides length cells among the children of a split. Fixed and
+// percentage sizes are allocated first, in order, for as long as there is
+// space. Whatever remains is then shared among the flexible children in
+// proportion to their weights.
+func splitLength(length int, children []*Layout) []int {
+	lengths := make([]int, len(children))
+	remaining := length
+	totalWeight := 0
+
+	for i, child := range children {
+		switch child.kind {
+		case sizeFixed:
+			lengths[i] = child.size
+		case sizePercent:
+			lengths[i] = length * child.size / 100
+		case sizeFlex:
+			totalWeight += child.size
+			continue
+		}
+		if lengths[i] > remaining {
+			lengths[i] = remaining
+		}
+		remaining -= lengths[i]
+	}
+
+	if totalWeight == 0 {
+		return lengths
+	}
+
+	shared := remaining
+	for i, child := range children {
+		if child.kind == sizeFlex {
+			lengths[i] = shared * child.size / totalWeight
+			remaining -= lengths[i]
+		}
+	}
+
+	// Hand out any cells lost to rounding, one per flexible child.
+	for i := 0; remaining > 0; i = (i + 1) % len(children) {
+		if children[i].kind == sizeFlex {
+			lengths[i]++
+			remaining--
+		}
+	}
+	return lengths
+}
+
+// drawBorder draws a box around the edge of the given rectangle, with the
+// title, if any, set into its top edge.
+func drawBorder(r Rect, title string) {
+	if r.Width < 2 || r.Height < 2 {
+		return
+	}
+
+	right := r.Left + r.Width - 1
+	bottom := r.Top + r.Height - 1
+	for x := r.Left + 1; x < right; x++ {
+		tb.SetCell(x, r.Top, '─', tb.ColorDefault, tb.ColorDefault)
+		tb.SetCell(x, bottom, '─', tb.ColorDefault, tb.ColorDefault)
+	}
+	for y := r.Top + 1; y < bottom; y++ {
+		tb.SetCell(r.Left, y, '│', tb.ColorDefault, tb.ColorDefault)
+		tb.SetCell(right, y, '│', tb.ColorDefault, tb.ColorDefault)
+	}
+	tb.SetCell(r.Left, r.Top, '┌', tb.ColorDefault, tb.ColorDefault)
+	tb.SetCell(right, r.Top, '┐', tb.ColorDefault, tb.ColorDefault)
+	tb.SetCell(r.Left, bottom, '└', tb.ColorDefault, tb.ColorDefault)
+	tb.SetCell(right, bottom, '┘', tb.ColorDefault, tb.ColorDefault)
+
+	if title == "" {
+		return
+	}
+	// Leave a corner and one line of border either side of the title.
+	x := r.Left + 2
+	for _, ch := range " " + title + " " {
+		if x >= right-1 {
+			break
+		}
+		tb.SetCell(x, r.Top, ch, tb.ColorDefault, tb.ColorDefault)
+		x++
+	}
+}
@@ -0,0 +1,29 @@
+package ugcli
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitLength(t *testing.T) {
+	tests := []struct {
+		name     string
+		length   int
+		children []*Layout
+		want     []int
+	}{
+		{"flex shares evenly", 10, []*Layout{Pane(0), Pane(1)}, []int{5, 5}},
+		{"rounding goes to the first flex", 10, []*Layout{Pane(0), Pane(1), Pane(2)}, []int{4, 3, 3}},
+		{"weights", 12, []*Layout{Pane(0).Flex(1), Pane(1).Flex(2)}, []int{4, 8}},
+		{"fixed then flex", 10, []*Layout{Pane(0).Fixed(3), Pane(1)}, []int{3, 7}},
+		{"percent then flex", 20, []*Layout{Pane(0).Percent(25), Pane(1)}, []int{5, 15}},
+		{"fixed overflows", 5, []*Layout{Pane(0).Fixed(4), Pane(1).Fixed(4), Pane(2)}, []int{4, 1, 0}},
+		{"no flex leaves space unused", 10, []*Layout{Pane(0).Fixed(3), Pane(1).Percent(50)}, []int{3, 5}},
+		{"no space", 0, []*Layout{Pane(0), Pane(1).Fixed(2)}, []int{0, 0}},
+	}
+	for _, tt := range tests {
+		if got := splitLength(tt.length, tt.children); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: splitLength(%d) = %v, want %v", tt.name, tt.length, got, tt.want)
+		}
+	}
+}
@@ -3,6 +3,10 @@
 package ugcli
 
 import (
+	"fmt"
+	"runtime/debug"
+	"sync"
+
 	tb "github.com/nsf/termbox-go"
 )
 
@@ -11,7 +15,8 @@ import (
 type Cli struct {
 
 	// activeComponent stores the index of which component/handler pair is
-	// currently active.
+	// currently active. The active component has focus, and receives all
+	// keyboard input.
 	activeComponent int
 
 	// components stores all sub-components that comprise this application.
@@ -20,14 +25,44 @@ type Cli struct {
 	// handlers stores the event queues to delegate events to various components.
 	handlers []*EventQueue
 
+	// stopped indicates, for each component, whether it has finished running.
+	stopped []bool
+
 	// runningComponents indicates the number of components currently running.
 	runningComponents int
 
+	// running indicates whether the application has been launched.
+	running bool
+
+	// focusLock guards the focus state (activeComponent, stopped,
+	// runningComponents and running), which may be changed from the goroutine
+	// of any component.
+	focusLock sync.Mutex
+
+	// focusKey is the key chord which moves focus to the next component.
+	focusKey Chord
+
+	// layout describes how the screen is divided among components, if it is
+	// managed by the application.
+	layout *Layout
+
+	// rects stores the area of the screen most recently assigned to each
+	// component placed by the layout.
+	rects map[Handle]Rect
+
+	// mouse indicates whether mouse events should be reported by the terminal.
+	mouse bool
+
+	// outputMode is the termbox output mode, which decides which colors can
+	// be drawn.
+	outputMode tb.OutputMode
+
 	// eventBuffer is a channel that will grab events from the termbox event poll.
 	eventBuffer chan tb.Event
 
-	// doneChan will send kill signals to the application.
-	doneChan chan bool
+	// doneChan will send kill signals to the application, along with the error
+	// that caused them, if any.
+	doneChan chan error
 }
 
 // NewCli will create a new CLI application.
@@ -36,17 +71,59 @@ func NewCli() *Cli {
 		activeComponent:   -1,
 		components:        []Component{},
 		handlers:          []*EventQueue{},
+		stopped:           []bool{},
 		runningComponents: 0,
+		focusKey:          defaultFocusKey,
+		rects:             map[Handle]Rect{},
+		mouse:             true,
+		outputMode:        tb.OutputNormal,
 		eventBuffer:       make(chan tb.Event, 10),
-		doneChan:          make(chan bool),
+		doneChan:          make(chan error),
 	}
 }
 
-// AddComponent will bind a subcomponent to this application.
-func (c *Cli) AddComponent(comp Component) {
+// Handle identifies a component that has been added to a Cli application.
+type Handle int
+
+// AddComponent will bind a subcomponent to this application, returning a
+// handle that can later be used to refer to it. The first component added
+// will initially have focus.
+func (c *Cli) AddComponent(comp Component) Handle {
+	c.focusLock.Lock()
+	defer c.focusLock.Unlock()
+
 	c.components = append(c.components, comp)
 	c.handlers = append(c.handlers, newEventQueue())
-	c.activeComponent = 0
+	c.stopped = append(c.stopped, false)
+	if c.activeComponent < 0 {
+		c.activeComponent = 0
+	}
+	return Handle(len(c.components) - 1)
+}
+
+// SetLayout sets the layout used to divide the screen among components. The
+// layout is applied when the application starts, and again whenever the
+// terminal is resized, with each component placed by it receiving an
+// EventLayout describing its area. It should be called before Run.
+func (c *Cli) SetLayout(l *Layout) {
+	c.layout = l
+}
+
+// SetMouse sets whether the application receives mouse events, such as the
+// mouse wheel being turned, which it does by default. While mouse events are
+// enabled, most terminals will not let the user select text with the mouse.
+// It should be called before Run.
+func (c *Cli) SetMouse(enabled bool) {
+	c.mouse = enabled
+}
+
+// SetOutputMode sets the termbox output mode the application draws in, which
+// decides which colors components can draw. By default, it is
+// tb.OutputNormal, which draws the 16 basic colors that every color terminal
+// supports. With tb.Output256, colors can be drawn from the 256 color palette.
+// It should be called before Run.
+func (c *Cli) SetOutputMode(mode tb.OutputMode) {
+	c.outputMode = mode
 }
 
 // eventPoll serves as a background goroutine to listen for events from termbox.
@@ -56,13 +133,58 @@ func (c *Cli) eventPoll() {
 	}
 }
 
-// Run launches the ugcli application.
-func (c *Cli) Run() {
+// Run launches the ugcli application. It initializes the terminal, runs
+// every component until all of them have finished, then restores the
+// terminal to its original state. The terminal is restored even if Run exits
+// due to an error or panic.
+//
+// If any component returns an error or panics, or termbox reports an error,
+// the application is stopped and that error is returned. Any other components
+// still running are left to finish by themselves, but can no longer draw. See
+// ScreenOpen.
+func (c *Cli) Run() error {
+	if err := tb.Init(); err != nil {
+		return err
+	}
+	setScreenOpen(true)
+	defer closeScreen()
+
+	// Report keys pressed while holding Alt as such, rather than as Esc
+	// followed by the key.
+	mode := tb.InputAlt
+	if c.mouse {
+		mode |= tb.InputMouse
+	}
+	tb.SetInputMode(mode)
+	tb.SetOutputMode(c.outputMode)
+
+	// Lay out the screen, and tell every component how big it is before they
+	// start running.
+	width, height := tb.Size()
+	if err := c.resize(tb.Event{
+		Type:   tb.EventResize,
+		Width:  width,
+		Height: height,
+	}); err != nil {
+		return err
+	}
+
+	c.focusLock.Lock()
+	c.running = true
+	// Leave room for every component to report how it finished, so that none
+	// of them block once the application has stopped listening.
+	c.doneChan = make(chan error, len(c.components))
 	for i := range c.components {
 		c.runningComponents++
 		// Launch each component in a new thread.
 		go c.runComponent(i)
 	}
+	// Let the initially active component know that it has focus.
+	focused := c.focusedHandler()
+	c.focusLock.Unlock()
+	if focused != nil {
+		focused.addEvent(newEvent(EventFocus))
+	}
 
 	// Start listening for termbox events.
 	go c.eventPoll()
@@ -73,31 +195,133 @@ func (c *Cli) Run() {
 		select {
 
 		// Either it comes from termbox, in which case it must be delegated to
-		// the appropriate component's event buffer.
+		// the appropriate component's event buffer, unless it is a request to
+		// move focus elsewhere or concerns every component.
 		case event := <-c.eventBuffer:
-			c.handlers[c.activeComponent].addEvent(event)
-		// Or it is a kill signal, in which case we should exit the application.
-		case done := <-c.doneChan:
-			if done {
-				return
+			if event.Type == tb.EventError {
+				return event.Err
+			} else if event.Type == tb.EventResize {
+				if err := c.resize(event); err != nil {
+					return err
+				}
+			} else if c.focusKey.Matches(event) {
+				c.FocusNext()
+			} else if event.Type == tb.EventMouse {
+				if handler := c.handlerAt(event.MouseX, event.MouseY); handler != nil {
+					handler.addEvent(Event{Event: event})
+				}
+			} else if handler := c.activeHandler(); handler != nil {
+				handler.addEvent(Event{Event: event})
 			}
+		// Or it is a kill signal, in which case we should exit the application.
+		case err := <-c.doneChan:
+			return err
 		}
 	}
 }
 
+// closeScreen restores the terminal, once no component is drawing on it.
+func closeScreen() {
+	LockScreen()
+	defer UnlockScreen()
+	screenOpen = false
+	tb.Close()
+}
+
+// resize handles the terminal being resized. The screen is cleared and laid
+// out again, then every running component is told about it so that each can
+// redraw itself: components placed by the layout receive an EventLayout with
+// their new area, and all others receive the resize event itself.
+func (c *Cli) resize(event tb.Event) error {
+	rects, err := c.arrange(event.Width, event.Height)
+	if err != nil {
+		return err
+	}
+	c.rects = rects
+
+	c.focusLock.Lock()
+	deliveries := []delivery{}
+	for i, handler := range c.handlers {
+		if c.stopped[i] {
+			continue
+		}
+		if r, placed := rects[Handle(i)]; placed {
+			deliveries = append(deliveries, delivery{handler, layoutEvent(r)})
+		} else {
+			deliveries = append(deliveries, delivery{handler, Event{Event: event}})
+		}
+	}
+	c.focusLock.Unlock()
+	deliver(deliveries)
+	return nil
+}
+
+// arrange clears the screen and, if the application has a layout, draws its
+// borders and works out the area of the screen belonging to each component
+// it places, given the size of the terminal.
+func (c *Cli) arrange(width, height int) (map[Handle]Rect, error) {
+	LockScreen()
+	defer UnlockScreen()
+
+	if err := tb.Clear(tb.ColorDefault, tb.ColorDefault); err != nil {
+		return nil, err
+	}
+
+	rects := map[Handle]Rect{}
+	if c.layout != nil {
+		c.layout.arrange(Rect{Width: width, Height: height}, rects)
+	}
+
+	// Ignore anything placed by the layout that isn't one of our components.
+	for h := range rects {
+		if h < 0 || int(h) >= len(c.components) {
+			delete(rects, h)
+		}
+	}
+	return rects, tb.Flush()
+}
+
 // runComponent is a wrapper thread for a given component.
 func (c *Cli) runComponent(comp int) {
-	c.components[comp].Run(c.handlers[comp])
+	err := c.runSafely(comp)
+	c.handlers[comp].stop()
 
+	c.focusLock.Lock()
+	c.stopped[comp] = true
 	c.runningComponents--
-	if c.runningComponents == 0 {
+	remaining := c.runningComponents
+	var deliveries []delivery
+	if comp == c.activeComponent {
+		// The focused component is gone, so hand focus to the next one.
+		deliveries = c.setFocus(c.nextRunning(1))
+	}
+	c.focusLock.Unlock()
+	deliver(deliveries)
+
+	if err != nil || remaining == 0 {
 		// Once the component stops running, send a kill signal iff this was the
-		// last running component.
-		c.doneChan <- true
+		// last running component, or if it failed.
+		c.doneChan <- err
 	}
 }
 
+// runSafely runs a given component, turning any panic into an error so that
+// a crashing component still lets the application restore the terminal.
+func (c *Cli) runSafely(comp int) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("ugcli: component %d panicked: %v\n%s", comp, r,
+				debug.Stack())
+		}
+	}()
+	return c.components[comp].Run(c.handlers[comp])
+}
+
 // Component represents a subcomponent that can be added to the ugcli app.
 type Component interface {
-	Run(*EventQueue)
+	// Run is called in its own goroutine once the application has started and
+	// the terminal is initialized. It should process events from the given
+	// queue until the component is finished, returning an error if the
+	// component could not continue.
+	Run(*EventQueue) error
 }
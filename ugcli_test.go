@@ -0,0 +1,46 @@
+package ugcli
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// funcComponent is a component which runs a function.
+type funcComponent func(*EventQueue) error
+
+func (f funcComponent) Run(eq *EventQueue) error { return f(eq) }
+
+func TestRunSafely(t *testing.T) {
+	failure := errors.New("failed")
+	c := NewCli()
+	c.AddComponent(funcComponent(func(*EventQueue) error { return nil }))
+	c.AddComponent(funcComponent(func(*EventQueue) error { return failure }))
+	c.AddComponent(funcComponent(func(*EventQueue) error { panic("oops") }))
+
+	if err := c.runSafely(0); err != nil {
+		t.Errorf("component 0 = %v, want nil", err)
+	}
+	if err := c.runSafely(1); err != failure {
+		t.Errorf("component 1 = %v, want %v", err, failure)
+	}
+	if err := c.runSafely(2); err == nil || !strings.Contains(err.Error(), "oops") {
+		t.Errorf("component 2 = %v, want an error reporting the panic", err)
+	}
+}
+
+func TestScreenOpen(t *testing.T) {
+	if ScreenOpen() {
+		t.Fatal("screen open before running")
+	}
+	setScreenOpen(true)
+	if !ScreenOpen() {
+		t.Error("screen not open while running")
+	}
+
+	// Once the application stops, components can no longer draw.
+	closeScreen()
+	if ScreenOpen() {
+		t.Error("screen open once stopped")
+	}
+}